@@ -0,0 +1,157 @@
+package spartan_go
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// The version byte at the start of every address, so that an address meant
+// for one network is never accepted on another.
+type Network byte
+
+const (
+	MAINNET Network = 0x3f
+	TESTNET Network = 0x7f
+	REGTEST Network = 0x6f
+
+	ADDRESS_HASH_LEN     = 20
+	ADDRESS_CHECKSUM_LEN = 4
+
+	BASE58_ALPHABET = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+var (
+	ErrInvalidAddress = errors.New("Address is not valid base58 of the expected length")
+	ErrBadChecksum    = errors.New("Address checksum does not match")
+	ErrUnknownNetwork = errors.New("Address is for an unknown network")
+	ErrWrongNetwork   = errors.New("Address is for a different network")
+)
+
+func (n Network) String() string {
+	switch n {
+	case MAINNET:
+		return "mainnet"
+	case TESTNET:
+		return "testnet"
+	case REGTEST:
+		return "regtest"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseNetwork(name string) (Network, error) {
+	for _, n := range []Network{MAINNET, TESTNET, REGTEST} {
+		if n.String() == name {
+			return n, nil
+		}
+	}
+	return 0, ErrUnknownNetwork
+}
+
+func (n Network) known() bool {
+	return n == MAINNET || n == TESTNET || n == REGTEST
+}
+
+// An address is the base58 encoding of the network byte, the first
+// ADDRESS_HASH_LEN bytes of the SHA-256 of the canonical public key encoding,
+// and a checksum over both. The network defaults to MAINNET.
+func CalcAddress(pubKey rsa.PublicKey, network ...Network) string {
+	net := MAINNET
+	if len(network) == 1 {
+		net = network[0]
+	}
+
+	e := &encoder{}
+	e.writePublicKey(pubKey)
+	keyHash := sha256.Sum256(e.buf)
+
+	payload := make([]byte, 0, 1+ADDRESS_HASH_LEN+ADDRESS_CHECKSUM_LEN)
+	payload = append(payload, byte(net))
+	payload = append(payload, keyHash[:ADDRESS_HASH_LEN]...)
+	payload = append(payload, addressChecksum(payload)...)
+	return base58Encode(payload)
+}
+
+// Returns the network and key hash encoded in addr. If a network is given,
+// addresses for any other network are rejected with ErrWrongNetwork.
+func ParseAddress(addr string, network ...Network) (Network, []byte, error) {
+	payload, ok := base58Decode(addr)
+	if !ok || len(payload) != 1+ADDRESS_HASH_LEN+ADDRESS_CHECKSUM_LEN {
+		return 0, nil, ErrInvalidAddress
+	}
+
+	body := payload[:1+ADDRESS_HASH_LEN]
+	if !bytes.Equal(addressChecksum(body), payload[1+ADDRESS_HASH_LEN:]) {
+		return 0, nil, ErrBadChecksum
+	}
+
+	net := Network(body[0])
+	if !net.known() {
+		return 0, nil, ErrUnknownNetwork
+	}
+	if len(network) == 1 && net != network[0] {
+		return 0, nil, ErrWrongNetwork
+	}
+	return net, body[1:], nil
+}
+
+func AddressMatchesKey(addr string, pubKey rsa.PublicKey) bool {
+	net, _, err := ParseAddress(addr)
+	if err != nil {
+		return false
+	}
+	return addr == CalcAddress(pubKey, net)
+}
+
+func addressChecksum(body []byte) []byte {
+	first := sha256.Sum256(body)
+	second := sha256.Sum256(first[:])
+	return second[:ADDRESS_CHECKSUM_LEN]
+}
+
+func base58Encode(input []byte) string {
+	n := new(big.Int).SetBytes(input)
+	radix := big.NewInt(int64(len(BASE58_ALPHABET)))
+	mod := new(big.Int)
+
+	out := make([]byte, 0, len(input)*138/100+1)
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, BASE58_ALPHABET[mod.Int64()])
+	}
+	// leading zero bytes are kept as leading '1's
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		out = append(out, BASE58_ALPHABET[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func base58Decode(input string) ([]byte, bool) {
+	n := new(big.Int)
+	radix := big.NewInt(int64(len(BASE58_ALPHABET)))
+	for i := 0; i < len(input); i++ {
+		digit := bytes.IndexByte([]byte(BASE58_ALPHABET), input[i])
+		if digit < 0 {
+			return nil, false
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+
+	zeroes := 0
+	for zeroes < len(input) && input[zeroes] == BASE58_ALPHABET[0] {
+		zeroes++
+	}
+	return append(make([]byte, zeroes), n.Bytes()...), true
+}
@@ -0,0 +1,146 @@
+package spartan_go
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// An amount of gold, counted in base units. A chain's AmountDecimals says how
+// many decimal places a gold has, so with 8 decimals one gold is 100000000
+// base units. Balances, outputs, fees and rewards are all amounts, and they
+// are only combined with the checked Add and Sub so that a total can never
+// silently wrap around.
+type Amount uint64
+
+const (
+	MAX_AMOUNT = Amount(math.MaxUint64)
+
+	GOLD_UNIT = "gold"
+
+	// 10^19 is the largest power of ten that fits in an Amount
+	MAX_AMOUNT_DECIMALS = uint(19)
+)
+
+var (
+	ErrAmountOverflow  = errors.New("Amount overflows")
+	ErrAmountUnderflow = errors.New("Amount underflows")
+	ErrInvalidAmount   = errors.New("Invalid amount")
+)
+
+func (a Amount) Add(b Amount) (Amount, error) {
+	if a > MAX_AMOUNT-b {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b > a {
+		return 0, ErrAmountUnderflow
+	}
+	return a - b, nil
+}
+
+func SumAmounts(amounts ...Amount) (Amount, error) {
+	total := Amount(0)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+func (a Amount) String() string {
+	return strconv.FormatUint(uint64(a), 10)
+}
+
+// The number of base units in one gold.
+func UnitsPerGold(decimals uint) Amount {
+	units := Amount(1)
+	for i := uint(0); i < decimals; i++ {
+		units *= 10
+	}
+	return units
+}
+
+// Parses an amount of gold such as "1.25 gold" or "1.25" into base units,
+// with AMOUNT_DECIMALS decimal places unless told otherwise. Parsing is strict
+// and never rounds: the number must be plain digits with an optional
+// fractional part, and any digits past the last decimal place must be zeros.
+func ParseAmount(s string, decimals ...uint) (Amount, error) {
+	places := AMOUNT_DECIMALS
+	if len(decimals) == 1 {
+		places = decimals[0]
+	}
+	if places > MAX_AMOUNT_DECIMALS {
+		return 0, fmt.Errorf("%w: at most %d decimal places are supported", ErrInvalidAmount, MAX_AMOUNT_DECIMALS)
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && fields[1] != GOLD_UNIT) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	whole, frac := fields[0], ""
+	if i := strings.IndexByte(whole, '.'); i >= 0 {
+		whole, frac = whole[:i], whole[i+1:]
+		if len(frac) == 0 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+	}
+	if len(whole) == 0 || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if uint(len(frac)) > places {
+		if strings.Trim(frac[places:], "0") != "" {
+			return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, places)
+		}
+		frac = frac[:places]
+	}
+
+	units := UnitsPerGold(places)
+	wholeGold, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil || Amount(wholeGold) > MAX_AMOUNT/units {
+		return 0, ErrAmountOverflow
+	}
+	fracUnits := Amount(0)
+	if len(frac) > 0 {
+		n, _ := strconv.ParseUint(frac, 10, 64)
+		fracUnits = Amount(n) * UnitsPerGold(places-uint(len(frac)))
+	}
+	return (Amount(wholeGold) * units).Add(fracUnits)
+}
+
+// Formats an amount of base units as gold, for example "1.25 gold", with
+// AMOUNT_DECIMALS decimal places unless told otherwise. The result is exact:
+// trailing zeros are dropped, but no other digits are.
+func FormatAmount(a Amount, decimals ...uint) string {
+	places := AMOUNT_DECIMALS
+	if len(decimals) == 1 {
+		places = decimals[0]
+	}
+	if places > MAX_AMOUNT_DECIMALS {
+		places = MAX_AMOUNT_DECIMALS
+	}
+	units := UnitsPerGold(places)
+	s := strconv.FormatUint(uint64(a/units), 10)
+	if frac := a % units; frac != 0 {
+		digits := strconv.FormatUint(uint64(frac), 10)
+		digits = strings.Repeat("0", int(places)-len(digits)) + digits
+		s += "." + strings.TrimRight(digits, "0")
+	}
+	return s + " " + GOLD_UNIT
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
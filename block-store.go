@@ -0,0 +1,379 @@
+package spartan_go
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrBlockNotFound = errors.New("Block not found in store")
+
+// A record cut short by a crash while it was being appended: the file ends
+// before the record does, or the record runs to the end of the file but its
+// checksum does not match.
+var errTornRecord = errors.New("Block record is incomplete")
+
+// Durable storage for the blocks a client has accepted. Blocks are only
+// stored once they have been validated, so a store always holds a block's
+// parent before the block itself.
+type BlockStore interface {
+	Put(b *Block) error
+	Get(hash string) (*Block, error)
+	Has(hash string) bool
+	HashesAtHeight(height uint) []string
+	MaxHeight() (uint, bool)
+	Close() error
+}
+
+// Keeps blocks in memory only. This is the default store for a client.
+type MemoryBlockStore struct {
+	blocks  map[string]*Block
+	heights map[uint][]string
+	lock    sync.RWMutex
+}
+
+func NewMemoryBlockStore() *MemoryBlockStore {
+	return &MemoryBlockStore{
+		blocks:  make(map[string]*Block),
+		heights: make(map[uint][]string),
+	}
+}
+
+func (s *MemoryBlockStore) Put(b *Block) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hash := b.HashVal()
+	if _, ok := s.blocks[hash]; ok {
+		return nil
+	}
+	s.blocks[hash] = b
+	s.heights[b.ChainLength] = append(s.heights[b.ChainLength], hash)
+	return nil
+}
+
+func (s *MemoryBlockStore) Get(hash string) (*Block, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if b, ok := s.blocks[hash]; ok {
+		return b, nil
+	}
+	return nil, ErrBlockNotFound
+}
+
+func (s *MemoryBlockStore) Has(hash string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.blocks[hash]
+	return ok
+}
+
+func (s *MemoryBlockStore) HashesAtHeight(height uint) []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]string{}, s.heights[height]...)
+}
+
+func (s *MemoryBlockStore) MaxHeight() (uint, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return maxHeight(s.heights)
+}
+
+func (s *MemoryBlockStore) Close() error {
+	return nil
+}
+
+const (
+	BLOCK_SEGMENT_MAX_SIZE = int64(64 << 20)
+	BLOCK_SEGMENT_SUFFIX   = ".seg"
+	BLOCK_RECORD_HEADER    = 8
+)
+
+type blockLocation struct {
+	segment int
+	offset  int64
+	length  uint32
+}
+
+// Stores blocks in append-only segment files inside a directory. Each record
+// is a uint32 payload length, a CRC-32 of the payload and the binary encoding
+// of the block. The index by hash and height is rebuilt by scanning the
+// segments when the store is opened; a partially written record at the end
+// of the newest segment (left behind by a crash) is truncated away, and any
+// other damage makes opening the store fail.
+type FileBlockStore struct {
+	dir            string
+	maxSegmentSize int64
+	segments       map[int]*os.File
+	current        int
+	currentSize    int64
+	locations      map[string]blockLocation
+	heights        map[uint][]string
+	lock           sync.RWMutex
+}
+
+func OpenFileBlockStore(dir string, maxSegmentSize ...int64) (*FileBlockStore, error) {
+	s := &FileBlockStore{
+		dir:            dir,
+		maxSegmentSize: BLOCK_SEGMENT_MAX_SIZE,
+		segments:       make(map[int]*os.File),
+		locations:      make(map[string]blockLocation),
+		heights:        make(map[uint][]string),
+	}
+	if len(maxSegmentSize) == 1 {
+		s.maxSegmentSize = maxSegmentSize[0]
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ids, err := s.segmentIds()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		ids = []int{0}
+	}
+
+	for i, id := range ids {
+		f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.segments[id] = f
+		size, err := s.scanSegment(id, f, i == len(ids)-1)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.current = id
+		s.currentSize = size
+	}
+	return s, nil
+}
+
+func (s *FileBlockStore) segmentPath(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%06d%s", id, BLOCK_SEGMENT_SUFFIX))
+}
+
+func (s *FileBlockStore) segmentIds() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, BLOCK_SEGMENT_SUFFIX) {
+			continue
+		}
+		var id int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, BLOCK_SEGMENT_SUFFIX), "%d", &id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Indexes every complete record in a segment and returns the segment's size.
+// Only the last segment may end in a torn record, which is truncated. Any
+// other damage, or a record that does not decode, is reported as an error
+// rather than throwing away the blocks after it.
+func (s *FileBlockStore) scanSegment(id int, f *os.File, last bool) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	offset := int64(0)
+	header := make([]byte, BLOCK_RECORD_HEADER)
+	for offset < size {
+		b, length, err := s.readRecord(f, offset, size, header)
+		if err != nil {
+			if !last || err != errTornRecord {
+				return 0, fmt.Errorf("Corrupt block store segment %s at offset %d: %v", s.segmentPath(id), offset, err)
+			}
+			if err := f.Truncate(offset); err != nil {
+				return 0, err
+			}
+			return offset, f.Sync()
+		}
+		s.index(b, blockLocation{segment: id, offset: offset, length: length})
+		offset += BLOCK_RECORD_HEADER + int64(length)
+	}
+	return offset, nil
+}
+
+// Reads the record at offset, which must end no later than limit.
+func (s *FileBlockStore) readRecord(f *os.File, offset int64, limit int64, header []byte) (*Block, uint32, error) {
+	if offset+BLOCK_RECORD_HEADER > limit {
+		return nil, 0, errTornRecord
+	}
+	if _, err := f.ReadAt(header, offset); err != nil {
+		if err == io.EOF {
+			err = errTornRecord
+		}
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	checksum := binary.BigEndian.Uint32(header[4:])
+	end := offset + BLOCK_RECORD_HEADER + int64(length)
+	if end > limit {
+		return nil, 0, errTornRecord
+	}
+	payload := make([]byte, length)
+	if _, err := f.ReadAt(payload, offset+BLOCK_RECORD_HEADER); err != nil {
+		if err == io.EOF {
+			err = errTornRecord
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		if end == limit {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, errors.New("Block record checksum does not match")
+	}
+	b, err := DeserializeBlock(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, length, nil
+}
+
+func (s *FileBlockStore) index(b *Block, loc blockLocation) {
+	hash := b.HashVal()
+	if _, ok := s.locations[hash]; ok {
+		return
+	}
+	s.locations[hash] = loc
+	s.heights[b.ChainLength] = append(s.heights[b.ChainLength], hash)
+}
+
+// The record is synced to disk before Put returns, so a block that has been
+// stored survives a crash.
+func (s *FileBlockStore) Put(b *Block) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.locations[b.HashVal()]; ok {
+		return nil
+	}
+	payload, err := b.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	record := make([]byte, BLOCK_RECORD_HEADER, BLOCK_RECORD_HEADER+len(payload))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+
+	if s.currentSize > 0 && s.currentSize+int64(len(record)) > s.maxSegmentSize {
+		if err := s.startSegment(s.current + 1); err != nil {
+			return err
+		}
+	}
+
+	f := s.segments[s.current]
+	if _, err := f.WriteAt(record, s.currentSize); err != nil {
+		// drop whatever part of the record made it to disk
+		f.Truncate(s.currentSize)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	s.index(b, blockLocation{segment: s.current, offset: s.currentSize, length: uint32(len(payload))})
+	s.currentSize += int64(len(record))
+	return nil
+}
+
+func (s *FileBlockStore) startSegment(id int) error {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if dir, err := os.Open(s.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	s.segments[id] = f
+	s.current = id
+	s.currentSize = 0
+	return nil
+}
+
+func (s *FileBlockStore) Get(hash string) (*Block, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	loc, ok := s.locations[hash]
+	if !ok {
+		return nil, ErrBlockNotFound
+	}
+	end := loc.offset + BLOCK_RECORD_HEADER + int64(loc.length)
+	b, _, err := s.readRecord(s.segments[loc.segment], loc.offset, end, make([]byte, BLOCK_RECORD_HEADER))
+	return b, err
+}
+
+func (s *FileBlockStore) Has(hash string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.locations[hash]
+	return ok
+}
+
+func (s *FileBlockStore) HashesAtHeight(height uint) []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]string{}, s.heights[height]...)
+}
+
+func (s *FileBlockStore) MaxHeight() (uint, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return maxHeight(s.heights)
+}
+
+func (s *FileBlockStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var firstErr error
+	for id, f := range s.segments {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.segments, id)
+	}
+	return firstErr
+}
+
+func maxHeight(heights map[uint][]string) (uint, bool) {
+	max, found := uint(0), false
+	for height := range heights {
+		if !found || height > max {
+			max, found = height, true
+		}
+	}
+	return max, found
+}
@@ -0,0 +1,135 @@
+package spartan_go
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func storeTestGenesis(t *testing.T, extraData string) *Block {
+	addr := CalcAddress(testTxKey(t).PublicKey, REGTEST)
+	g, err := MakeGenesis(&Blockchain{
+		Params:           RegTestParams(),
+		StartingBalances: map[string]Amount{addr: 1000},
+		ExtraData:        extraData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// Stores two blocks and returns their hashes and the path of the segment
+// holding them.
+func fillTestStore(t *testing.T, dir string) ([]string, string) {
+	s, err := OpenFileBlockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := make([]string, 0, 2)
+	for _, extraData := range []string{"first", "second"} {
+		b := storeTestGenesis(t, extraData)
+		if err := s.Put(b); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, b.HashVal())
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return hashes, filepath.Join(dir, "000000"+BLOCK_SEGMENT_SUFFIX)
+}
+
+func appendToFile(t *testing.T, path string, data []byte) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+// A record with a valid checksum around a payload that does not decode.
+func undecodableRecord() []byte {
+	payload := []byte("not a block")
+	record := make([]byte, BLOCK_RECORD_HEADER, BLOCK_RECORD_HEADER+len(payload))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	return append(record, payload...)
+}
+
+func TestFileBlockStoreTruncatesTornRecord(t *testing.T) {
+	for name, tail := range map[string][]byte{
+		"short header":   {0, 0},
+		"short payload":  {0, 0, 0, 100, 1, 2, 3, 4, 5},
+		"wrong checksum": append([]byte{0, 0, 0, 3, 1, 2, 3, 4}, 5, 6, 7),
+	} {
+		dir := t.TempDir()
+		hashes, path := fillTestStore(t, dir)
+		size := fileSize(t, path)
+		appendToFile(t, path, tail)
+
+		s, err := OpenFileBlockStore(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, hash := range hashes {
+			if !s.Has(hash) {
+				t.Errorf("%s: lost block %s", name, hash)
+			}
+		}
+		s.Close()
+		if got := fileSize(t, path); got != size {
+			t.Errorf("%s: segment is %d bytes after truncation, want %d", name, got, size)
+		}
+	}
+}
+
+func TestFileBlockStoreRefusesUndecodableRecord(t *testing.T) {
+	dir := t.TempDir()
+	_, path := fillTestStore(t, dir)
+	appendToFile(t, path, undecodableRecord())
+	size := fileSize(t, path)
+
+	if s, err := OpenFileBlockStore(dir); err == nil {
+		s.Close()
+		t.Fatal("store opened with a record that does not decode")
+	}
+	if got := fileSize(t, path); got != size {
+		t.Errorf("segment was truncated from %d to %d bytes", size, got)
+	}
+}
+
+func TestFileBlockStoreRefusesBadChecksumBeforeEnd(t *testing.T) {
+	dir := t.TempDir()
+	_, path := fillTestStore(t, dir)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flip a byte in the first record's payload
+	data[BLOCK_RECORD_HEADER] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := OpenFileBlockStore(dir); err == nil {
+		s.Close()
+		t.Fatal("store opened with a damaged record before the end")
+	}
+	if got := fileSize(t, path); got != int64(len(data)) {
+		t.Errorf("segment was truncated from %d to %d bytes", len(data), got)
+	}
+}
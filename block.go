@@ -1,6 +1,9 @@
 package spartan_go
 
 import (
+	"encoding/json"
+	"errors"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -8,46 +11,74 @@ import (
 	"github.com/holiman/uint256"
 )
 
+// The fields of a block that are covered by the proof-of-work. The
+// transactions themselves are committed to through MerkleRoot, and the
+// balances and nonces after applying them through StateRoot.
+type BlockHeader struct {
+	PrevBlockHash  string
+	MerkleRoot     string
+	StateRoot      string
+	Timestamp      time.Time
+	Target         *uint256.Int
+	CoinbaseReward Amount
+	RewardAddr     string
+	RewardShares   []RewardShare
+	ChainLength    uint
+	Proof          uint
+	ExtraData      string
+}
+
+const BLOCK_ENCODING_VERSION = byte(1)
+
 type Block struct {
 	RewardAddr     string
+	RewardShares   []RewardShare
 	Proof          uint
 	PrevBlock      *Block
 	PrevBlockHash  string
+	MerkleRoot     string
+	StateRoot      string
 	Target         *uint256.Int
-	CoinbaseReward uint
-	Balances       map[string]uint
-	NextNonce      map[string]uint
-	Transactions   map[string]*Transaction
+	CoinbaseReward Amount
+	state          *AccountState
+	params         *ChainParams
+	Transactions   []*Transaction
+	txIndex        map[string]int
 	ChainLength    uint
+	ExtraData      string
+	chainWork      *uint256.Int
 	Timestamp      time.Time
 	lock           sync.Mutex
 }
 
-func NewBlock(rewardAddr string, prevBlock *Block, target *uint256.Int, coinbaseReward ...uint) *Block {
+// If params is nil, the block uses the parameters of prevBlock, or mainnet if
+// there is no previous block.
+func NewBlock(params *ChainParams, rewardAddr string, prevBlock *Block, target *uint256.Int, coinbaseReward ...Amount) *Block {
 	if prevBlock != nil {
 		prevBlock.lock.Lock()
 		defer prevBlock.lock.Unlock()
 	}
+	if params == nil && prevBlock != nil {
+		params = prevBlock.params
+	}
+	if params == nil {
+		params = MainNetParams()
+	}
 
 	newBlock := &Block{}
+	newBlock.params = params
 	newBlock.RewardAddr = rewardAddr
-	newBlock.Balances = make(map[string]uint)
-	newBlock.NextNonce = make(map[string]uint)
-	newBlock.Transactions = make(map[string]*Transaction)
+	newBlock.Transactions = make([]*Transaction, 0)
+	newBlock.txIndex = make(map[string]int)
 
 	if prevBlock != nil {
+		newBlock.PrevBlock = prevBlock
 		newBlock.PrevBlockHash = prevBlock.HashVal()
 		newBlock.ChainLength = prevBlock.ChainLength + 1
-		for k, v := range prevBlock.Balances {
-			newBlock.Balances[k] = v
-		}
-		for k, v := range prevBlock.NextNonce {
-			newBlock.NextNonce[k] = v
-		}
-		if len(prevBlock.RewardAddr) != 0 {
-			winnerBalance := newBlock.BalanceOf(prevBlock.RewardAddr)
-			newBlock.Balances[prevBlock.RewardAddr] = winnerBalance + prevBlock.TotalRewards()
-		}
+		newBlock.state = prevBlock.state
+		// a reward that would overflow a balance is left out here, and
+		// receivers reject the block when they rerun it
+		newBlock.creditMaturedRewards(prevBlock)
 	} else {
 		newBlock.ChainLength = 0
 	}
@@ -55,15 +86,18 @@ func NewBlock(rewardAddr string, prevBlock *Block, target *uint256.Int, coinbase
 	if target != nil {
 		newBlock.Target = target
 	} else {
-		newBlock.Target = POW_TARGET
+		newBlock.Target = params.PowTarget
 	}
+	newBlock.setChainWork(prevBlock)
 
 	if coinbaseReward != nil {
 		newBlock.CoinbaseReward = coinbaseReward[0]
 	} else {
-		newBlock.CoinbaseReward = COINBASE_AMT_ALLOWED
+		newBlock.CoinbaseReward = params.SubsidyAt(newBlock.ChainLength)
 	}
 
+	newBlock.MerkleRoot = newBlock.calcMerkleRoot()
+	newBlock.StateRoot = newBlock.state.Root()
 	newBlock.Timestamp = time.Now()
 	return newBlock
 }
@@ -72,6 +106,17 @@ func (b *Block) IsGenesisBlock() bool {
 	return b.ChainLength == 0
 }
 
+// Returns the block n blocks before this one, or the oldest block reachable
+// through PrevBlock if the chain is not that long. PrevBlock is only set once
+// a block has been connected to its parent.
+func (b *Block) Ancestor(n uint) *Block {
+	block := b
+	for i := uint(0); i < n && block.PrevBlock != nil; i++ {
+		block = block.PrevBlock
+	}
+	return block
+}
+
 func (b *Block) HasValidProof() bool {
 	h := b.HashVal()
 
@@ -84,117 +129,569 @@ func (b *Block) HasValidProof() bool {
 	return n.Cmp(b.Target) < 0
 }
 
+func (b *Block) Header() *BlockHeader {
+	return &BlockHeader{
+		PrevBlockHash:  b.PrevBlockHash,
+		MerkleRoot:     b.MerkleRoot,
+		StateRoot:      b.StateRoot,
+		Timestamp:      b.Timestamp,
+		Target:         b.Target,
+		CoinbaseReward: b.CoinbaseReward,
+		RewardAddr:     b.RewardAddr,
+		RewardShares:   b.RewardShares,
+		ChainLength:    b.ChainLength,
+		Proof:          b.Proof,
+		ExtraData:      b.ExtraData,
+	}
+}
+
 func (b *Block) Serialize() string {
 	if b == nil {
 		return ""
 	}
-	return b.RewardAddr + b.PrevBlockHash + strconv.FormatUint(uint64(b.Proof), 10)
+	return b.Header().Serialize()
 }
 
 func (b *Block) HashVal() string {
 	return Hash(b.Serialize(), "")
 }
 
-func (b *Block) BalanceOf(addr string) uint {
-	if balance, ok := b.Balances[addr]; ok {
-		return balance
+// The header is hashed over the same length-prefixed encoding that starts a
+// block's binary encoding, so no two different headers serialize alike.
+func (h *BlockHeader) Serialize() string {
+	e := &encoder{}
+	h.encode(e)
+	return string(e.buf)
+}
+
+func (h *BlockHeader) encode(e *encoder) {
+	e.writeByte(BLOCK_ENCODING_VERSION)
+	e.writeString(h.PrevBlockHash)
+	e.writeString(h.MerkleRoot)
+	e.writeString(h.StateRoot)
+	e.writeUint64(uint64(h.Timestamp.UnixNano()))
+	e.writeUint256(h.Target)
+	e.writeUint64(uint64(h.CoinbaseReward))
+	e.writeString(h.RewardAddr)
+	e.writeCount(len(h.RewardShares))
+	for _, share := range h.RewardShares {
+		e.writeString(share.Address)
+		e.writeUint64(uint64(share.Weight))
+	}
+	e.writeUint64(uint64(h.ChainLength))
+	e.writeUint64(uint64(h.Proof))
+	e.writeString(h.ExtraData)
+}
+
+func (h *BlockHeader) HashVal() string {
+	return Hash(h.Serialize(), "")
+}
+
+// Transactions are committed to in the order they were added to the block,
+// which is also the order in which they are applied to the balances.
+func (b *Block) txIds() []string {
+	txIds := make([]string, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		txIds = append(txIds, tx.Id())
+	}
+	return txIds
+}
+
+func (b *Block) calcMerkleRoot() string {
+	return MerkleRoot(b.txIds())
+}
+
+func (b *Block) MerkleProof(txId string) (*MerkleProof, error) {
+	txIds := b.txIds()
+	for i, id := range txIds {
+		if id == txId {
+			return &MerkleProof{
+				TxId:   txId,
+				Header: b.Header(),
+				Branch: MerkleBranch(txIds, i),
+			}, nil
+		}
+	}
+	return nil, errors.New("Transaction " + txId + " is not in block " + b.HashVal())
+}
+
+func (b *Block) HasValidMerkleRoot() bool {
+	return b.MerkleRoot == b.calcMerkleRoot()
+}
+
+// The expected number of hashes needed to find a proof for the block's
+// target, which is 2^256 / (target + 1). Computed as
+// ^target / (target + 1) + 1 so that it fits in 256 bits.
+func (b *Block) Work() *uint256.Int {
+	target := b.Target
+	if target == nil {
+		target = b.powLimit()
+	}
+	denominator := new(uint256.Int).AddUint64(target, 1)
+	work := new(uint256.Int).Not(target)
+	work.Div(work, denominator)
+	return work.AddUint64(work, 1)
+}
+
+// The easiest target allowed on the block's chain.
+func (b *Block) powLimit() *uint256.Int {
+	if b.params == nil || b.params.PowTarget == nil {
+		return POW_TARGET
+	}
+	return b.params.PowTarget
+}
+
+// The total work of the chain ending in this block. For a block that has not
+// been connected to its parent yet, this is only the block's own work.
+func (b *Block) ChainWork() *uint256.Int {
+	if b.chainWork == nil {
+		return b.Work()
+	}
+	return b.chainWork
+}
+
+func (b *Block) setChainWork(prevBlock *Block) {
+	if prevBlock == nil {
+		b.chainWork = b.Work()
 	} else {
-		return 0
+		b.chainWork = new(uint256.Int).Add(prevBlock.ChainWork(), b.Work())
 	}
 }
 
-func (b *Block) TotalRewards() uint {
+func (b *Block) BalanceOf(addr string) Amount {
+	return b.state.BalanceOf(addr)
+}
+
+func (b *Block) NextNonce(addr string) uint {
+	return b.state.NextNonce(addr)
+}
+
+// A snapshot of every balance as of this block.
+func (b *Block) Balances() map[string]Amount {
+	return b.state.Balances()
+}
+
+func (b *Block) Params() *ChainParams {
+	return b.params
+}
+
+func (b *Block) State() *AccountState {
+	return b.state
+}
+
+// Only meaningful once the block's balances have been computed, either by
+// building it locally or by rerunning it on top of its parent.
+func (b *Block) HasValidStateRoot() bool {
+	return b.StateRoot == b.state.Root()
+}
+
+func (b *Block) StateProof(addr string) *StateProof {
+	proof := b.state.Prove(addr)
+	proof.Header = b.Header()
+	return proof
+}
+
+// The total weight of the block's transactions.
+func (b *Block) Weight() uint {
+	weight := uint(0)
+	for _, tx := range b.Transactions {
+		weight += tx.Weight()
+	}
+	return weight
+}
+
+// Blocks without parameters yet, such as ones just deserialized, are not
+// limited until they are rerun on top of their parent.
+func (b *Block) maxWeight() uint {
+	if b.params == nil || b.params.MaxBlockWeight == 0 {
+		return ^uint(0)
+	}
+	return b.params.MaxBlockWeight
+}
+
+// addTransaction only accepts fees that keep this total from overflowing, so
+// it is capped only for blocks that were never checked.
+func (b *Block) TotalRewards() Amount {
 	reward := b.CoinbaseReward
 	for _, tx := range b.Transactions {
-		reward += tx.Fee
+		reward = addCapped(reward, tx.Fee)
 	}
 	return reward
 }
 
+// The index is rebuilt lazily so that blocks built without NewBlock can
+// still be looked up by transaction id.
+func (b *Block) index() map[string]int {
+	if b.txIndex == nil || len(b.txIndex) != len(b.Transactions) {
+		b.txIndex = make(map[string]int)
+		for i, tx := range b.Transactions {
+			b.txIndex[tx.Id()] = i
+		}
+	}
+	return b.txIndex
+}
+
+func (b *Block) coinbaseMaturity() uint {
+	if b.params == nil || b.params.CoinbaseMaturity == 0 {
+		return 1
+	}
+	return b.params.CoinbaseMaturity
+}
+
+// The rewards of a block can be spent once it has CoinbaseMaturity
+// confirmations, so they are credited to the balances of the block that
+// many blocks after it. Until then they only count as immature.
+func (b *Block) creditMaturedRewards(prevBlock *Block) error {
+	maturity := b.coinbaseMaturity()
+	if b.ChainLength < maturity {
+		return nil
+	}
+	matured := prevBlock.Ancestor(maturity - 1)
+	if matured.ChainLength+maturity != b.ChainLength {
+		return nil
+	}
+	for _, payout := range matured.RewardPayouts() {
+		winnerBalance, err := b.BalanceOf(payout.Address).Add(payout.Amount)
+		if err != nil {
+			return err
+		}
+		b.state = b.state.SetBalance(payout.Address, winnerBalance)
+	}
+	return nil
+}
+
+// The rewards earned by addr in this block and its ancestors that have not
+// been credited to its balance yet.
+func (b *Block) ImmatureBalanceOf(addr string) Amount {
+	immature := Amount(0)
+	block := b
+	for i := uint(0); i < b.coinbaseMaturity() && block != nil; i++ {
+		for _, payout := range block.RewardPayouts() {
+			if payout.Address == addr {
+				immature = addCapped(immature, payout.Amount)
+			}
+		}
+		block = block.PrevBlock
+	}
+	return immature
+}
+
 func (b *Block) Contains(txId string) bool {
-	_, ok := b.Transactions[txId]
+	_, ok := b.index()[txId]
 	return ok
 }
 
-func (b *Block) AddTransaction(tx *Transaction, client *Client) bool {
-	if _, ok := b.Transactions[tx.Id()]; ok {
-		if client != nil {
-			client.log("Duplicate transaction " + tx.Id())
-		}
-		return false
+func (b *Block) GetTransaction(txId string) *Transaction {
+	if i, ok := b.index()[txId]; ok {
+		return b.Transactions[i]
+	}
+	return nil
+}
+
+// Applies tx to the block's balances. If client is given, the reason for a
+// rejection is also logged.
+func (b *Block) AddTransaction(tx *Transaction, client *Client) error {
+	err := b.addTransaction(tx)
+	if err != nil && client != nil {
+		client.log(err.Error())
+	}
+	return err
+}
+
+func (b *Block) addTransaction(tx *Transaction) error {
+	if b.Contains(tx.Id()) {
+		return &TxError{TxId: tx.Id(), Err: ErrDuplicateTransaction}
 	} else if len(tx.sig) == 0 {
-		if client != nil {
-			client.log("Unsigned transaction " + tx.Id())
-		}
-		return false
+		return &TxError{TxId: tx.Id(), Err: ErrUnsignedTransaction}
 	} else if !tx.ValidSignature() {
-		if client != nil {
-			client.log("Invalid signature for transaction " + tx.Id())
-		}
-		return false
-	} else if !tx.SufficientFunds(b) {
-		if client != nil {
-			client.log("Insufficient gold for transaction " + tx.Id())
+		return &TxError{TxId: tx.Id(), Err: ErrInvalidSignature}
+	}
+	if b.params != nil {
+		if err := tx.CheckAddresses(b.params.Network); err != nil {
+			return &TxError{TxId: tx.Id(), Err: err}
 		}
-		return false
 	}
-
-	nonce, ok := b.NextNonce[tx.From]
-	if !ok {
-		nonce = 0
+	if b.Weight()+tx.Weight() > b.maxWeight() {
+		return &TxError{TxId: tx.Id(), Err: ErrBlockFull}
+	}
+	totalOutput, err := tx.TotalOutput()
+	if err != nil {
+		return &TxError{TxId: tx.Id(), Err: err}
 	}
+	if _, err := b.TotalRewards().Add(tx.Fee); err != nil {
+		return &TxError{TxId: tx.Id(), Err: err}
+	}
+	if !tx.SufficientFunds(b) {
+		return &TxError{TxId: tx.Id(), Err: ErrInsufficientFunds}
+	}
+
+	nonce := b.NextNonce(tx.From)
 	if tx.Nonce < nonce {
-		if client != nil {
-			client.log("Replayed transaction " + tx.Id())
-		}
-		return false
+		return &TxError{TxId: tx.Id(), Err: ErrNonceTooLow}
 	} else if tx.Nonce > nonce {
-		if client != nil {
-			client.log("Out of order transaction " + tx.Id())
-		}
-		return false
+		return &TxError{TxId: tx.Id(), Err: ErrNonceGap}
 	}
-	b.NextNonce[tx.From] = nonce + 1
 
-	b.Transactions[tx.Id()] = tx
+	// credit the outputs on a copy of the state, so that a transaction
+	// rejected part way through leaves the block unchanged
 	senderBalance := b.BalanceOf(tx.From)
-	b.Balances[tx.From] = senderBalance - tx.TotalOutput()
-
+	state := b.state.SetBalance(tx.From, senderBalance-totalOutput)
 	for _, output := range tx.Outputs {
-		oldBalance := b.BalanceOf(output.Address)
-		b.Balances[output.Address] = output.Amount + oldBalance
+		newBalance, err := state.BalanceOf(output.Address).Add(output.Amount)
+		if err != nil {
+			return &TxError{TxId: tx.Id(), Err: err}
+		}
+		state = state.SetBalance(output.Address, newBalance)
 	}
+	b.state = state.SetNextNonce(tx.From, nonce+1)
 
-	return true
+	b.index()[tx.Id()] = len(b.Transactions)
+	b.Transactions = append(b.Transactions, tx)
+	b.MerkleRoot = b.calcMerkleRoot()
+	b.StateRoot = b.state.Root()
+
+	return nil
 }
 
-func (b *Block) rerun(prevBlock *Block) bool {
+// A copy of the block as it was received, without the state, parent and
+// chain work that a client computes when it connects the block to its chain.
+// The transactions themselves are never modified, so they are shared.
+func (b *Block) receivedCopy() *Block {
+	return &Block{
+		RewardAddr:     b.RewardAddr,
+		RewardShares:   b.RewardShares,
+		Proof:          b.Proof,
+		PrevBlockHash:  b.PrevBlockHash,
+		MerkleRoot:     b.MerkleRoot,
+		StateRoot:      b.StateRoot,
+		Target:         b.Target,
+		CoinbaseReward: b.CoinbaseReward,
+		Transactions:   append([]*Transaction(nil), b.Transactions...),
+		ChainLength:    b.ChainLength,
+		ExtraData:      b.ExtraData,
+		Timestamp:      b.Timestamp,
+	}
+}
+
+// Replays the block's transactions on top of prevBlock in a scratch block and
+// only then compares the roots the block claims with the recomputed ones. The
+// header and body of b are never touched while it is being checked, and b
+// only takes on the recomputed state once it has passed.
+func (b *Block) rerun(prevBlock *Block) error {
+	scratch := &Block{
+		RewardAddr:     b.RewardAddr,
+		RewardShares:   b.RewardShares,
+		PrevBlock:      prevBlock,
+		PrevBlockHash:  b.PrevBlockHash,
+		Target:         b.Target,
+		CoinbaseReward: b.CoinbaseReward,
+		ChainLength:    b.ChainLength,
+		state:          prevBlock.state,
+		params:         prevBlock.params,
+		Transactions:   make([]*Transaction, 0, len(b.Transactions)),
+		txIndex:        make(map[string]int),
+	}
+	if err := scratch.creditMaturedRewards(prevBlock); err != nil {
+		return err
+	}
+	for _, tx := range b.Transactions {
+		if err := scratch.addTransaction(tx); err != nil {
+			return err
+		}
+	}
+	if scratch.calcMerkleRoot() != b.MerkleRoot {
+		return ErrBadMerkleRoot
+	}
+	if scratch.state.Root() != b.StateRoot {
+		return ErrBadStateRoot
+	}
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
+	b.PrevBlock = prevBlock
+	b.params = prevBlock.params
+	b.state = scratch.state
+	b.setChainWork(prevBlock)
+	return nil
+}
+
+// The binary encoding of a block is its header fields followed by its
+// transactions, each as a length-prefixed transaction encoding. Balances are
+// only included for the genesis block, since every other block recomputes
+// them from its parent when it is received.
+func (b *Block) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	b.Header().encode(e)
 
-	b.Balances = make(map[string]uint)
-	b.NextNonce = make(map[string]uint)
-	for key, val := range prevBlock.Balances {
-		b.Balances[key] = val
+	e.writeCount(len(b.Transactions))
+	for _, tx := range b.Transactions {
+		txBytes, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		e.writeBytes(txBytes)
 	}
-	for key, val := range prevBlock.NextNonce {
-		b.NextNonce[key] = val
+
+	balances := b.genesisBalances()
+	addrs := make([]string, 0, len(balances))
+	for addr := range balances {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	e.writeCount(len(addrs))
+	for _, addr := range addrs {
+		e.writeString(addr)
+		e.writeUint64(uint64(balances[addr]))
+	}
+	if e.err != nil {
+		return nil, e.err
 	}
+	return e.buf, nil
+}
 
-	winnerBalance := b.BalanceOf(prevBlock.RewardAddr)
-	if len(prevBlock.RewardAddr) != 0 {
-		b.Balances[prevBlock.RewardAddr] = winnerBalance + prevBlock.TotalRewards()
+func (b *Block) UnmarshalBinary(data []byte) error {
+	d := &decoder{buf: data}
+	if version := d.readByte(); d.err == nil && version != BLOCK_ENCODING_VERSION {
+		return errors.New("Unsupported block encoding version " + strconv.Itoa(int(version)))
+	}
+	prevBlockHash := d.readString()
+	merkleRoot := d.readString()
+	stateRoot := d.readString()
+	timestamp := int64(d.readUint64())
+	target := d.readUint256()
+	coinbaseReward := d.readUint64()
+	rewardAddr := d.readString()
+	count := d.readCount()
+	var rewardShares []RewardShare
+	for i := 0; i < count && d.err == nil; i++ {
+		address := d.readString()
+		weight := d.readUint64()
+		rewardShares = append(rewardShares, RewardShare{Address: address, Weight: uint(weight)})
 	}
+	chainLength := d.readUint64()
+	proof := d.readUint64()
+	extraData := d.readString()
 
-	txs := b.Transactions
-	b.Transactions = make(map[string]*Transaction)
-	for _, tx := range txs {
-		success := b.AddTransaction(tx, nil)
-		if !success {
-			return false
+	count = d.readCount()
+	txs := make([]*Transaction, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		tx := &Transaction{}
+		td := &decoder{buf: d.readBytes()}
+		if d.err != nil {
+			break
 		}
+		tx.decode(td)
+		if err := td.finish(); err != nil {
+			return err
+		}
+		txs = append(txs, tx)
+	}
+
+	count = d.readCount()
+	balances := make(map[string]Amount)
+	for i := 0; i < count && d.err == nil; i++ {
+		addr := d.readString()
+		balances[addr] = Amount(d.readUint64())
+	}
+	if err := d.finish(); err != nil {
+		return err
 	}
-	return true
+
+	b.PrevBlockHash = prevBlockHash
+	b.MerkleRoot = merkleRoot
+	b.StateRoot = stateRoot
+	b.Timestamp = time.Unix(0, timestamp)
+	b.Target = target
+	b.CoinbaseReward = Amount(coinbaseReward)
+	b.RewardAddr = rewardAddr
+	b.RewardShares = rewardShares
+	b.ChainLength = uint(chainLength)
+	b.Proof = uint(proof)
+	b.ExtraData = extraData
+	b.setBody(txs, balances)
+	return nil
+}
+
+type blockJSON struct {
+	PrevBlockHash  string            `json:"prevBlockHash"`
+	MerkleRoot     string            `json:"merkleRoot"`
+	StateRoot      string            `json:"stateRoot"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Target         string            `json:"target"`
+	CoinbaseReward Amount            `json:"coinbaseReward"`
+	RewardAddr     string            `json:"rewardAddr"`
+	RewardShares   []RewardShare     `json:"rewardShares,omitempty"`
+	ChainLength    uint              `json:"chainLength"`
+	Proof          uint              `json:"proof"`
+	ExtraData      string            `json:"extraData,omitempty"`
+	Transactions   []*Transaction    `json:"transactions"`
+	Balances       map[string]Amount `json:"balances,omitempty"`
+}
+
+func (b *Block) MarshalJSON() ([]byte, error) {
+	target := ""
+	if b.Target != nil {
+		target = b.Target.Hex()
+	}
+	return json.Marshal(&blockJSON{
+		PrevBlockHash:  b.PrevBlockHash,
+		MerkleRoot:     b.MerkleRoot,
+		StateRoot:      b.StateRoot,
+		Timestamp:      b.Timestamp,
+		Target:         target,
+		CoinbaseReward: b.CoinbaseReward,
+		RewardAddr:     b.RewardAddr,
+		RewardShares:   b.RewardShares,
+		ChainLength:    b.ChainLength,
+		Proof:          b.Proof,
+		ExtraData:      b.ExtraData,
+		Transactions:   b.Transactions,
+		Balances:       b.genesisBalances(),
+	})
+}
+
+func (b *Block) UnmarshalJSON(data []byte) error {
+	o := &blockJSON{}
+	if err := json.Unmarshal(data, o); err != nil {
+		return err
+	}
+	target, err := uint256.FromHex(o.Target)
+	if err != nil {
+		return errors.New("Invalid block target " + o.Target)
+	}
+
+	b.PrevBlockHash = o.PrevBlockHash
+	b.MerkleRoot = o.MerkleRoot
+	b.StateRoot = o.StateRoot
+	b.Timestamp = o.Timestamp
+	b.Target = target
+	b.CoinbaseReward = o.CoinbaseReward
+	b.RewardAddr = o.RewardAddr
+	b.RewardShares = o.RewardShares
+	b.ChainLength = o.ChainLength
+	b.Proof = o.Proof
+	b.ExtraData = o.ExtraData
+	b.setBody(o.Transactions, o.Balances)
+	return nil
+}
+
+func (b *Block) genesisBalances() map[string]Amount {
+	if !b.IsGenesisBlock() {
+		return nil
+	}
+	return b.Balances()
 }
 
-// toJSON() isn't used for anything so I omitted it
+func (b *Block) setBody(txs []*Transaction, balances map[string]Amount) {
+	if txs == nil {
+		txs = make([]*Transaction, 0)
+	}
+	b.Transactions = txs
+	b.txIndex = nil
+	b.state = NewAccountState()
+	if b.IsGenesisBlock() {
+		for addr, balance := range balances {
+			b.state = b.state.SetBalance(addr, balance)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package spartan_go
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A mined block on top of genesis with txCount payments from sender.
+func paymentsTestBlock(t *testing.T, sender *Client, genesis *Block, to string, txCount int) *Block {
+	b := NewBlock(nil, sender.Address, genesis, genesis.Target)
+	b.Timestamp = genesis.Timestamp.Add(time.Minute)
+	for nonce := 0; nonce < txCount; nonce++ {
+		tx := &Transaction{
+			Fee:     genesis.Params().DefaultTxFee,
+			From:    sender.Address,
+			Nonce:   uint(nonce),
+			PubKey:  sender.key.PublicKey,
+			Outputs: []TxOuput{{Amount: 1, Address: to}},
+		}
+		tx.Sign(sender.key)
+		if err := b.addTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for !b.HasValidProof() {
+		b.Proof++
+	}
+	return b
+}
+
+// FakeNet hands every client the same *Block, so clients must be able to
+// check it at the same time without seeing each other's partial work.
+func TestClientsReceiveSharedBlockConcurrently(t *testing.T) {
+	params := RegTestParams()
+	for round := 0; round < 5; round++ {
+		net := NewFakeNet(&FakeNet{})
+		clients := []*Client{
+			NewClient(&Client{Name: "A", Net: net, Params: params}),
+			NewClient(&Client{Name: "B", Net: net, Params: params}),
+			NewClient(&Client{Name: "C", Net: net, Params: params}),
+		}
+		balances := make(map[*Client]Amount)
+		for _, client := range clients {
+			balances[client] = 1000 * params.DefaultTxFee
+		}
+		genesis, err := MakeGenesis(&Blockchain{
+			Params:           params,
+			ClientBalanceMap: balances,
+			Timestamp:        time.Now().Add(-time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := paymentsTestBlock(t, clients[0], genesis, clients[1].Address, 30)
+
+		errs := make([]error, len(clients))
+		var wg sync.WaitGroup
+		for i, client := range clients {
+			wg.Add(1)
+			go func(i int, client *Client) {
+				defer wg.Done()
+				errs[i] = client.ProcessBlock(b)
+			}(i, client)
+		}
+		wg.Wait()
+
+		for i, client := range clients {
+			if errs[i] != nil {
+				t.Errorf("round %d: %s rejected the block: %v", round, client.Name, errs[i])
+			} else if client.LastBlock.HashVal() != b.HashVal() {
+				t.Errorf("round %d: %s did not move to the block", round, client.Name)
+			}
+		}
+	}
+}
+
+// The subsidy and reward maturity depend on the height a block claims, so it
+// must be exactly one more than its parent's.
+func TestReceiveBlockRejectsWrongHeight(t *testing.T) {
+	params := RegTestParams()
+	params.HalvingInterval = 2
+	client := NewClient(&Client{Name: "Tester", Net: NewFakeNet(&FakeNet{}), Params: params})
+	genesis, err := MakeGenesis(&Blockchain{
+		Params:           params,
+		ClientBalanceMap: map[*Client]Amount{client: 1000},
+		Timestamp:        time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, height := range []uint{0, 2, 5, ^uint(0)} {
+		b := NewBlock(nil, client.Address, genesis, genesis.Target)
+		b.Timestamp = genesis.Timestamp.Add(time.Minute)
+		b.ChainLength = height
+		b.CoinbaseReward = params.SubsidyAt(height)
+		for !b.HasValidProof() {
+			b.Proof++
+		}
+		if err := client.ProcessBlock(b); err == nil {
+			t.Errorf("block at height %d on top of genesis was accepted", height)
+		} else if height != 0 && !errors.Is(err, ErrBadHeight) {
+			t.Errorf("height %d: got %v, want %v", height, err, ErrBadHeight)
+		}
+	}
+	if client.LastBlock.HashVal() != genesis.HashVal() {
+		t.Error("client moved off genesis")
+	}
+}
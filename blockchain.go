@@ -2,6 +2,8 @@ package spartan_go
 
 import (
 	"errors"
+	"strconv"
+	"time"
 
 	"github.com/holiman/uint256"
 )
@@ -10,13 +12,11 @@ import (
 // out blockClass/transactionClass and assuming that it is Block/Transaction
 // from this package
 type Blockchain struct {
-	ClientBalanceMap map[*Client]uint
-	StartingBalances map[string]uint
-	powTarget        *uint256.Int
-	powLeadingZeroes uint
-	coinbaseAmount   uint
-	defaultTxFee     uint
-	confirmedDepth   uint
+	ClientBalanceMap map[*Client]Amount
+	StartingBalances map[string]Amount
+	Params           *ChainParams
+	Timestamp        time.Time
+	ExtraData        string
 }
 
 const (
@@ -25,17 +25,26 @@ const (
 	PROOF_FOUND      = "PROOF_FOUND"
 	START_MINING     = "START_MINING"
 
+	// defaults for MainNetParams
 	NUM_ROUNDS_MINING = uint(2000)
 
 	POW_LEADING_ZEROES = uint(19)
 
-	COINBASE_AMT_ALLOWED = uint(25)
-	DEFAULT_TX_FEE       = uint(1)
+	// in gold, which is AMOUNT_DECIMALS decimal places of base units
+	AMOUNT_DECIMALS           = uint(8)
+	COINBASE_AMT_ALLOWED      = Amount(25)
+	COINBASE_HALVING_INTERVAL = uint(210000)
+	COINBASE_MATURITY         = uint(6)
+	DEFAULT_TX_FEE            = Amount(1)
 
 	CONFIRMED_DEPTH = uint(6)
+
+	// in weight units, which are bytes of canonical transaction encoding
+	MAX_BLOCK_WEIGHT = uint(1 << 16)
+	// in base units per FEE_RATE_WEIGHT weight units
+	MIN_RELAY_FEE_RATE = Amount(1000)
 )
 
-var blockchain = &Blockchain{}
 var POW_TARGET, _ = uint256.FromHex("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
 
 func MakeGenesis(cfg *Blockchain) (*Block, error) {
@@ -46,24 +55,35 @@ func MakeGenesis(cfg *Blockchain) (*Block, error) {
 		return &Block{}, errors.New("You may set clientBalanceMap XOR set startingBalances, but not both")
 	}
 
-	blockchain = cfg
-	blockchain.powTarget = POW_TARGET.Rsh(POW_TARGET, POW_LEADING_ZEROES)
+	params := cfg.Params
+	if params == nil {
+		params = MainNetParams()
+	}
 
-	var balances map[string]uint
+	var balances map[string]Amount
 	if cfg.ClientBalanceMap != nil {
-		balances = make(map[string]uint)
+		balances = make(map[string]Amount)
 		for client, balance := range cfg.ClientBalanceMap {
 			balances[client.Address] = balance
 		}
 	} else {
 		balances = cfg.StartingBalances
 	}
+	// every starting balance must fit in the block's binary encoding
+	if len(balances) > MAX_ENCODED_ITEMS {
+		return &Block{}, errors.New("At most " + strconv.Itoa(MAX_ENCODED_ITEMS) + " starting balances are allowed")
+	}
 
-	g := NewBlock("", nil, nil)
-	g.Balances = make(map[string]uint)
+	g := NewBlock(params, "", nil, params.PowTarget)
+	if !cfg.Timestamp.IsZero() {
+		g.Timestamp = cfg.Timestamp
+	}
+	g.ExtraData = cfg.ExtraData
+	g.state = NewAccountState()
 	for addr, balance := range balances {
-		g.Balances[addr] = balance
+		g.state = g.state.SetBalance(addr, balance)
 	}
+	g.StateRoot = g.state.Root()
 
 	if cfg.ClientBalanceMap != nil {
 		for client := range cfg.ClientBalanceMap {
@@ -74,7 +94,68 @@ func MakeGenesis(cfg *Blockchain) (*Block, error) {
 	return g, nil
 }
 
-// DeserializeBlock, MakeBlock and MakeTransaction are not implemented because
-// taking object-like interface{} as parameter is discouraged in GoLang unless
-// absolutely necessary. All objects will take defined structs instead of the
-// all encompassing interface{}
+// Decodes a block from its binary encoding and checks that it is internally
+// consistent. Checks that depend on the parent block are left to the client
+// that receives it.
+//
+// A genesis block has no proof of work to check, so if genesisHash is given
+// a block at height 0 is rejected with ErrUnknownGenesis unless it has that
+// hash. Only data that is already trusted, such as a client's own store,
+// should be decoded without it.
+func DeserializeBlock(data []byte, genesisHash ...string) (*Block, error) {
+	b := &Block{}
+	if err := b.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if err := validateDeserializedBlock(b, genesisHash...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func DeserializeBlockJSON(data []byte, genesisHash ...string) (*Block, error) {
+	b := &Block{}
+	if err := b.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	if err := validateDeserializedBlock(b, genesisHash...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func DeserializeTransaction(data []byte) (*Transaction, error) {
+	tx := &Transaction{}
+	if err := tx.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if !tx.ValidSignature() {
+		return nil, &TxError{TxId: tx.Id(), Err: ErrInvalidSignature}
+	}
+	return tx, nil
+}
+
+func validateDeserializedBlock(b *Block, genesisHash ...string) error {
+	if b.IsGenesisBlock() {
+		if len(genesisHash) == 1 && b.HashVal() != genesisHash[0] {
+			return &BlockError{Hash: b.HashVal(), Err: ErrUnknownGenesis}
+		}
+		if len(b.PrevBlockHash) != 0 || len(b.Transactions) != 0 {
+			return &BlockError{Hash: b.HashVal(), Err: ErrUnknownGenesis}
+		}
+	} else if !b.HasValidProof() {
+		return &BlockError{Hash: b.HashVal(), Err: ErrInvalidProof}
+	}
+	if !b.HasValidMerkleRoot() {
+		return &BlockError{Hash: b.HashVal(), Err: ErrBadMerkleRoot}
+	}
+	if b.IsGenesisBlock() && !b.HasValidStateRoot() {
+		return &BlockError{Hash: b.HashVal(), Err: ErrBadStateRoot}
+	}
+	for _, tx := range b.Transactions {
+		if !tx.ValidSignature() {
+			return &BlockError{Hash: b.HashVal(), Err: &TxError{TxId: tx.Id(), Err: ErrInvalidSignature}}
+		}
+	}
+	return nil
+}
@@ -5,8 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strconv"
 	"sync"
+	"time"
 
 	. "github.com/vansante/go-event-emitter"
 )
@@ -20,6 +20,12 @@ type Client struct {
 	pendingOutgoingTransactions map[string]*Transaction
 	pendingReceivedTransactions map[string]*Transaction
 	blocks                      map[string]*Block
+	Store                       BlockStore
+	Params                      *ChainParams
+	ForkChoice                  ForkChoice
+	Clock                       Clock
+	Mempool                     *Mempool
+	FeeEstimator                *FeeEstimator
 	pendingBlocks               map[string][]*Block
 	StartingBlock               *Block
 	LastBlock                   *Block
@@ -39,6 +45,30 @@ func NewClient(cfg *Client) *Client {
 		pendingReceivedTransactions: make(map[string]*Transaction),
 		blocks:                      make(map[string]*Block),
 		pendingBlocks:               make(map[string][]*Block),
+		Store:                       cfg.Store,
+		Params:                      cfg.Params,
+		ForkChoice:                  cfg.ForkChoice,
+		Clock:                       cfg.Clock,
+		Mempool:                     cfg.Mempool,
+		FeeEstimator:                cfg.FeeEstimator,
+	}
+	if client.Params == nil {
+		client.Params = MainNetParams()
+	}
+	if client.ForkChoice == nil {
+		client.ForkChoice = MostWorkForkChoice{}
+	}
+	if client.Clock == nil {
+		client.Clock = SystemClock{}
+	}
+	if client.Store == nil {
+		client.Store = NewMemoryBlockStore()
+	}
+	if client.Mempool == nil {
+		client.Mempool = NewMempool(&Mempool{Clock: client.Clock, MinRelayFeeRate: client.Params.MinRelayFeeRate})
+	}
+	if client.FeeEstimator == nil {
+		client.FeeEstimator = NewFeeEstimator(&FeeEstimator{FallbackFee: client.Params.DefaultTxFee, MinFeeRate: client.Params.MinRelayFeeRate})
 	}
 
 	if cfg.key == nil {
@@ -46,14 +76,18 @@ func NewClient(cfg *Client) *Client {
 	} else {
 		client.key = cfg.key
 	}
-	client.Address = CalcAddress(client.key.PublicKey)
+	client.Address = CalcAddress(client.key.PublicKey, client.Params.Network)
 
 	if cfg.StartingBlock != nil {
 		client.setGenesisBlock(cfg.StartingBlock)
 	}
+	if err := client.loadBlocks(); err != nil {
+		client.log("Could not load stored blocks: " + err.Error())
+	}
 
 	client.AddListener(PROOF_FOUND, client.receiveBlock)
 	client.AddListener(MISSING_BLOCK, client.provideMissingBlock)
+	client.AddListener(POST_TRANSACTION, client.receiveTransaction)
 
 	return client
 }
@@ -63,70 +97,237 @@ func (c *Client) setGenesisBlock(startingBlock *Block) error {
 		return errors.New("Cannot set genesis block for existing blockchain")
 	}
 
+	if startingBlock.params == nil {
+		startingBlock.params = c.Params
+	}
 	c.LastConfirmedBlock = startingBlock
 	c.LastBlock = startingBlock
+	c.Mempool.SetTip(startingBlock)
 	c.blocks[startingBlock.HashVal()] = startingBlock
+	return c.Store.Put(startingBlock)
+}
+
+// Replays the blocks in the store in order of height, which restores
+// LastBlock and LastConfirmedBlock to where they were when the client last
+// ran. If no genesis block was configured, the stored one is used.
+func (c *Client) loadBlocks() error {
+	maxHeight, ok := c.Store.MaxHeight()
+	if !ok {
+		return nil
+	}
+	for height := uint(0); height <= maxHeight; height++ {
+		for _, hash := range c.Store.HashesAtHeight(height) {
+			if _, ok := c.blocks[hash]; ok {
+				continue
+			}
+			b, err := c.Store.Get(hash)
+			if err != nil {
+				return err
+			}
+			if b.IsGenesisBlock() {
+				if c.LastBlock == nil {
+					c.setGenesisBlock(b)
+				} else {
+					c.log("Ignoring stored genesis block " + hash)
+				}
+				continue
+			}
+			if _, ok := c.blocks[b.PrevBlockHash]; !ok {
+				c.log("Ignoring stored block " + hash + " with unknown parent")
+				continue
+			}
+			c.receiveBlockHelper(b)
+		}
+	}
 	return nil
 }
 
-func (c *Client) ConfirmedBalance() uint {
+func (c *Client) Close() error {
+	return c.Store.Close()
+}
+
+// The timestamp for a new block on top of prevBlock: the current time, but
+// never at or before the median time past of prevBlock.
+func (c *Client) nextTimestamp(prevBlock *Block) time.Time {
+	now := c.Clock.Now()
+	if median := prevBlock.MedianTimePast(c.Params.MedianTimeSpan); !now.After(median) {
+		return median.Add(time.Nanosecond)
+	}
+	return now
+}
+
+// The balance as of the last confirmed block that the client can spend. Block
+// rewards that have not matured yet are not included.
+func (c *Client) ConfirmedBalance() Amount {
 	return c.LastConfirmedBlock.BalanceOf(c.Address)
 }
 
-func (c *Client) AvailableGold() uint {
-	pendingSpent := uint(0)
+// Block rewards earned as of the last confirmed block that cannot be spent
+// yet.
+func (c *Client) ImmatureBalance() Amount {
+	return c.LastConfirmedBlock.ImmatureBalanceOf(c.Address)
+}
+
+// The confirmed balance less everything spent by pending transactions, or
+// zero if the pending transactions spend more than is confirmed.
+func (c *Client) AvailableGold() Amount {
+	pendingSpent := Amount(0)
 	for _, tx := range c.pendingOutgoingTransactions {
-		pendingSpent += tx.TotalOutput()
+		totalOutput, err := tx.TotalOutput()
+		if err == nil {
+			pendingSpent, err = pendingSpent.Add(totalOutput)
+		}
+		if err != nil {
+			return 0
+		}
+	}
+	available, err := c.ConfirmedBalance().Sub(pendingSpent)
+	if err != nil {
+		return 0
+	}
+	return available
+}
+
+// The fee rate, per FEE_RATE_WEIGHT weight units, a transaction needs to be
+// confirmed within the given number of blocks, judging by recent blocks and
+// the mempool.
+func (c *Client) EstimateFeeRate(blocks uint) Amount {
+	return c.FeeEstimator.EstimateFeeRate(c.LastBlock, c.Mempool, blocks)
+}
+
+// The fee a transaction needs to be confirmed within the given number of
+// blocks. Unless a weight is given, the transaction is assumed to be a
+// payment to a single address.
+func (c *Client) EstimateFee(blocks uint, weight ...uint) Amount {
+	txWeight := (&Transaction{
+		From:    c.Address,
+		PubKey:  c.key.PublicKey,
+		Outputs: []TxOuput{{Address: c.Address}},
+	}).Weight()
+	if len(weight) == 1 {
+		txWeight = weight[0]
 	}
-	return c.ConfirmedBalance() - pendingSpent
+	return c.FeeEstimator.EstimateFee(c.LastBlock, c.Mempool, blocks, txWeight)
 }
 
-func (c *Client) PostTransaction(outputs []TxOuput, fee ...uint) (*Transaction, error) {
-	txFee := DEFAULT_TX_FEE
-	if len(fee) == 1 {
-		txFee = fee[0]
+// Posts a transaction paying the outputs. Without a fee, it pays the fee
+// estimated for confirmation within DEFAULT_FEE_TARGET_BLOCKS blocks.
+func (c *Client) PostTransaction(outputs []TxOuput, fee ...Amount) (*Transaction, error) {
+	tx := &Transaction{
+		Outputs: outputs,
+		From:    c.Address,
+		Nonce:   c.nonce,
+		PubKey:  c.key.PublicKey,
+	}
+	if len(fee) != 1 {
+		tx.Fee = c.EstimateFee(DEFAULT_FEE_TARGET_BLOCKS, tx.Weight())
+	} else {
+		tx.Fee = fee[0]
+	}
+	if err := tx.CheckAddresses(c.Params.Network); err != nil {
+		return nil, err
 	}
-	totalPayments := txFee
-	for _, output := range outputs {
-		totalPayments += output.Amount
+	totalPayments, err := tx.TotalOutput()
+	if err != nil {
+		return nil, err
 	}
 	if totalPayments > c.AvailableGold() {
-		return nil, errors.New("Requested " + strconv.FormatUint(uint64(totalPayments), 10) + ", but account only has " + strconv.FormatUint(uint64(c.AvailableGold()), 10))
-	}
-	return c.postGenericTransaction(
-		&Transaction{
-			Outputs: outputs,
-			Fee:     txFee,
-			From:    c.Address,
-			Nonce:   c.nonce,
-			PubKey:  c.key.PublicKey,
-		},
-	), nil
+		return nil, fmt.Errorf("%w: requested %s, but account only has %s", ErrInsufficientFunds, c.Params.FormatAmount(totalPayments), c.Params.FormatAmount(c.AvailableGold()))
+	}
+	return c.postGenericTransaction(tx)
+}
+
+// Re-issues a pending transaction with the same nonce and a higher fee, so
+// that it replaces the original if that has not been mined yet. The outputs
+// stay the same unless new ones are given. The fee must be high enough for
+// the mempool to accept the replacement.
+func (c *Client) ReplaceTransaction(txId string, fee Amount, outputs ...TxOuput) (*Transaction, error) {
+	old, ok := c.pendingOutgoingTransactions[txId]
+	if !ok {
+		return nil, &TxError{TxId: txId, Err: ErrNotPending}
+	}
+	if len(outputs) == 0 {
+		outputs = old.Outputs
+	}
+	tx := &Transaction{
+		Outputs: outputs,
+		Fee:     fee,
+		From:    c.Address,
+		Nonce:   old.Nonce,
+		PubKey:  c.key.PublicKey,
+	}
+	totalPayments, err := tx.TotalOutput()
+	if err != nil {
+		return nil, err
+	}
+	oldTotal, _ := old.TotalOutput()
+	available := addCapped(c.AvailableGold(), oldTotal)
+	if totalPayments > available {
+		return nil, fmt.Errorf("%w: requested %s, but account only has %s", ErrInsufficientFunds, c.Params.FormatAmount(totalPayments), c.Params.FormatAmount(available))
+	}
+
+	tx.Sign(c.key)
+	if err := c.Mempool.Add(tx); err != nil {
+		return nil, err
+	}
+	delete(c.pendingOutgoingTransactions, txId)
+	c.pendingOutgoingTransactions[tx.Id()] = tx
+	c.Net.Broadcast(POST_TRANSACTION, tx)
+	return tx, nil
+}
+
+// Pays an amount of gold such as "1.25 gold" to addr, with the estimated fee.
+func (c *Client) PostPayment(addr string, amount string) (*Transaction, error) {
+	units, err := c.Params.ParseAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	return c.PostTransaction([]TxOuput{{Amount: units, Address: addr}})
 }
 
-func (c *Client) postGenericTransaction(tx *Transaction) *Transaction {
+// Signs tx and hands it to the mempool. Only a transaction the mempool
+// accepts is recorded as pending and uses up the nonce.
+func (c *Client) postGenericTransaction(tx *Transaction) (*Transaction, error) {
 	tx.Sign(c.key)
+	if err := c.Mempool.Add(tx); err != nil {
+		return nil, err
+	}
 	c.pendingOutgoingTransactions[tx.Id()] = tx
 	c.nonce++
 	c.Net.Broadcast(POST_TRANSACTION, tx)
-	return tx
+	return tx, nil
 }
 
-func (c *Client) receiveBlockHelper(b *Block) *Block {
+// Validates b and connects it to the chain. The returned error is a
+// *BlockError; a block whose parent is unknown is queued until the parent
+// arrives and reported with ErrUnknownParent.
+func (c *Client) receiveBlockHelper(b *Block) (*Block, error) {
 	if b == nil {
-		return nil
+		return nil, nil
 	}
 	if _, ok := c.blocks[b.HashVal()]; ok {
-		return nil
+		return nil, &BlockError{Hash: b.HashVal(), Err: ErrDuplicateBlock}
 	}
 
-	if !b.HasValidProof() && !b.IsGenesisBlock() {
-		c.log("Block " + b.HashVal() + " does not have a valid proof.")
-		return nil
+	// the only genesis block a client follows is the one it started with,
+	// which is already known, so any other block at height 0 is a forgery
+	if b.IsGenesisBlock() {
+		return nil, c.rejectBlock(b, ErrUnknownGenesis)
+	}
+	// other clients may be checking and connecting the same block, so this
+	// client works on its own copy
+	b = b.receivedCopy()
+
+	if !b.HasValidProof() {
+		return nil, c.rejectBlock(b, ErrInvalidProof)
+	}
+
+	if !b.HasValidMerkleRoot() {
+		return nil, c.rejectBlock(b, ErrBadMerkleRoot)
 	}
 
 	prevBlock, ok := c.blocks[b.PrevBlockHash]
-	if !ok && !b.IsGenesisBlock() {
+	if !ok {
 		c.pendingBlocksLock.Lock()
 		stuckBlocks, ok := c.pendingBlocks[b.PrevBlockHash]
 		if !ok {
@@ -145,20 +346,45 @@ func (c *Client) receiveBlockHelper(b *Block) *Block {
 		}
 		c.pendingBlocks[b.PrevBlockHash] = stuckBlocks
 		c.pendingBlocksLock.Unlock()
-		return nil
+		return nil, &BlockError{Hash: b.HashVal(), Err: ErrUnknownParent}
 	}
 
-	if !b.IsGenesisBlock() {
-		success := b.rerun(prevBlock)
-		if !success {
-			return nil
+	if b.ChainLength != prevBlock.ChainLength+1 {
+		return nil, c.rejectBlock(b, ErrBadHeight)
+	}
+	if err := checkTimestamp(b, prevBlock, c.Params.MedianTimeSpan, c.Params.MaxFutureDrift, c.Clock.Now()); err != nil {
+		return nil, c.rejectBlock(b, err)
+	}
+	if !b.Target.Eq(c.Params.DifficultyAdjuster.NextTarget(prevBlock)) {
+		return nil, c.rejectBlock(b, ErrBadTarget)
+	}
+	if b.CoinbaseReward != c.Params.SubsidyAt(b.ChainLength) {
+		return nil, c.rejectBlock(b, ErrBadCoinbase)
+	}
+	if len(b.RewardAddr) != 0 {
+		if _, _, err := ParseAddress(b.RewardAddr, c.Params.Network); err != nil {
+			return nil, c.rejectBlock(b, &AddressError{Address: b.RewardAddr, Err: err})
 		}
 	}
+	if !b.hasValidRewardShares(c.Params.Network) {
+		return nil, c.rejectBlock(b, ErrBadRewardShares)
+	}
+	if c.Params.MaxBlockWeight != 0 && b.Weight() > c.Params.MaxBlockWeight {
+		return nil, c.rejectBlock(b, ErrBlockTooHeavy)
+	}
+	if err := b.rerun(prevBlock); err != nil {
+		return nil, c.rejectBlock(b, err)
+	}
 
 	c.blocks[b.HashVal()] = b
-	if c.LastBlock.ChainLength < b.ChainLength {
+	if err := c.Store.Put(b); err != nil {
+		c.log("Could not store block " + b.HashVal() + ": " + err.Error())
+	}
+	if c.ForkChoice.Prefer(b, c.LastBlock) {
+		oldTip := c.LastBlock
 		c.LastBlock = b
 		c.setLastConfirmed()
+		c.Mempool.SetTip(b, disconnectedTransactions(oldTip, b)...)
 	}
 
 	c.pendingBlocksLock.Lock()
@@ -174,7 +400,35 @@ func (c *Client) receiveBlockHelper(b *Block) *Block {
 		c.receiveBlockHelper(unstuckBlock)
 	}
 
-	return b
+	return b, nil
+}
+
+// The transactions in the blocks of oldTip's chain that are not on newTip's
+// chain, which are no longer confirmed after switching to newTip.
+func disconnectedTransactions(oldTip *Block, newTip *Block) []*Transaction {
+	txs := make([]*Transaction, 0)
+	for oldTip != nil && newTip != nil && oldTip != newTip {
+		if oldTip.ChainLength >= newTip.ChainLength {
+			txs = append(txs, oldTip.Transactions...)
+			oldTip = oldTip.PrevBlock
+		} else {
+			newTip = newTip.PrevBlock
+		}
+	}
+	return txs
+}
+
+func (c *Client) rejectBlock(b *Block, err error) error {
+	blockErr := &BlockError{Hash: b.HashVal(), Err: err}
+	c.log(blockErr.Error())
+	return blockErr
+}
+
+// Validates and connects a block, as if it had been received from the
+// network.
+func (c *Client) ProcessBlock(b *Block) error {
+	_, err := c.receiveBlockHelper(b)
+	return err
 }
 
 func (c *Client) receiveBlock(block ...interface{}) {
@@ -187,6 +441,19 @@ func (c *Client) receiveBlock(block ...interface{}) {
 	c.receiveBlockHelper(b)
 }
 
+// Adds a transaction broadcast by another client to the mempool.
+func (c *Client) receiveTransaction(txs ...interface{}) {
+	if len(txs) != 1 {
+		c.log("receiveTransaction(...) requires 1 transaction parameter")
+		return
+	}
+	tx := txs[0].(*Transaction)
+	newTx := NewTransaction(tx.From, tx.Nonce, tx.PubKey, tx.sig, tx.Fee, tx.Outputs)
+	if err := c.Mempool.Add(newTx); err != nil && !errors.Is(err, ErrDuplicateTransaction) {
+		c.log(err.Error())
+	}
+}
+
 func (c *Client) requestMissingBlock(block *Block) {
 	c.log("Asking for missing block " + block.HashVal())
 	c.Net.Broadcast(MISSING_BLOCK, c.Address, block.PrevBlockHash)
@@ -212,18 +479,22 @@ func (c *Client) resendPendingTransactions() {
 
 func (c *Client) setLastConfirmed() {
 	block := c.LastBlock
-	confirmedBlockHeight := block.ChainLength - CONFIRMED_DEPTH
-	if confirmedBlockHeight < 0 {
-		confirmedBlockHeight = 0
+	confirmedBlockHeight := uint(0)
+	if block.ChainLength > c.Params.ConfirmedDepth {
+		confirmedBlockHeight = block.ChainLength - c.Params.ConfirmedDepth
 	}
 	for block.ChainLength > confirmedBlockHeight {
 		block = c.blocks[block.PrevBlockHash]
 	}
 	c.LastConfirmedBlock = block
 
+	// a transaction whose nonce has been used is either confirmed, or was
+	// replaced by another one that was confirmed instead, so it can never be
+	// included any more
+	nextNonce := c.LastConfirmedBlock.NextNonce(c.Address)
 	toDelete := make([]string, 0)
-	for txId := range c.pendingOutgoingTransactions {
-		if c.LastConfirmedBlock.Contains(txId) {
+	for txId, tx := range c.pendingOutgoingTransactions {
+		if tx.Nonce < nextNonce || c.LastConfirmedBlock.Contains(txId) {
 			toDelete = append(toDelete, txId)
 		}
 	}
@@ -242,8 +513,8 @@ func (c *Client) log(msg string) {
 
 func (c *Client) ShowAllBalances() {
 	c.log("Showing balances:")
-	for id, balance := range c.LastConfirmedBlock.Balances {
-		c.log("	" + id + ":" + strconv.FormatUint(uint64(balance), 10))
+	for id, balance := range c.LastConfirmedBlock.Balances() {
+		c.log("	" + id + ": " + c.Params.FormatAmount(balance))
 	}
 }
 
@@ -0,0 +1,49 @@
+package spartan_go
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	MEDIAN_TIME_SPAN = uint(11)
+	MAX_FUTURE_DRIFT = 2 * time.Hour
+)
+
+// The source of the current time for a client, so that tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// The median timestamp of this block and the n-1 blocks before it.
+func (b *Block) MedianTimePast(n uint) time.Time {
+	if n == 0 {
+		n = 1
+	}
+	timestamps := make([]time.Time, 0, n)
+	for block := b; block != nil && uint(len(timestamps)) < n; block = block.PrevBlock {
+		timestamps = append(timestamps, block.Timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+	return timestamps[len(timestamps)/2]
+}
+
+// A block's timestamp must be later than the median time past of its parent,
+// and no more than maxDrift ahead of now.
+func checkTimestamp(b *Block, prevBlock *Block, span uint, maxDrift time.Duration, now time.Time) error {
+	if !b.Timestamp.After(prevBlock.MedianTimePast(span)) {
+		return ErrTimestampTooOld
+	}
+	if b.Timestamp.After(now.Add(maxDrift)) {
+		return ErrTimestampInFuture
+	}
+	return nil
+}
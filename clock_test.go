@@ -0,0 +1,116 @@
+package spartan_go
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A Clock that only moves when a test sets or advances it.
+type testClock struct {
+	now  time.Time
+	lock sync.Mutex
+}
+
+func newTestClock(now time.Time) *testClock {
+	return &testClock{now: now}
+}
+
+func (c *testClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = now
+}
+
+var testClockStart = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// A chain of blocks one minute apart, starting at testClockStart, with its
+// tip at the given height.
+func timedTestChain(height uint) *Block {
+	b := NewBlock(RegTestParams(), "", nil, nil)
+	b.Timestamp = testClockStart
+	for i := uint(0); i < height; i++ {
+		next := NewBlock(nil, "", b, b.Target)
+		next.Timestamp = b.Timestamp.Add(time.Minute)
+		b = next
+	}
+	return b
+}
+
+func TestMedianTimePast(t *testing.T) {
+	tip := timedTestChain(20)
+	// the median of the last 11 blocks is the 6th from the tip
+	if mtp, want := tip.MedianTimePast(MEDIAN_TIME_SPAN), tip.Timestamp.Add(-5*time.Minute); !mtp.Equal(want) {
+		t.Errorf("median time past %v, want %v", mtp, want)
+	}
+	// a short chain uses every block it has
+	short := timedTestChain(2)
+	if mtp, want := short.MedianTimePast(MEDIAN_TIME_SPAN), testClockStart.Add(time.Minute); !mtp.Equal(want) {
+		t.Errorf("median time past of short chain %v, want %v", mtp, want)
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	prev := timedTestChain(20)
+	mtp := prev.MedianTimePast(MEDIAN_TIME_SPAN)
+	clock := newTestClock(prev.Timestamp)
+	limit := clock.Now().Add(MAX_FUTURE_DRIFT)
+
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      error
+	}{
+		{"before median time past", mtp.Add(-time.Second), ErrTimestampTooOld},
+		{"at median time past", mtp, ErrTimestampTooOld},
+		{"just after median time past", mtp.Add(time.Nanosecond), nil},
+		{"at drift limit", limit, nil},
+		{"past drift limit", limit.Add(time.Nanosecond), ErrTimestampInFuture},
+	}
+	for _, test := range tests {
+		b := NewBlock(nil, "", prev, prev.Target)
+		b.Timestamp = test.timestamp
+		if err := checkTimestamp(b, prev, MEDIAN_TIME_SPAN, MAX_FUTURE_DRIFT, clock.Now()); err != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestReceiveBlockUsesClientClock(t *testing.T) {
+	clock := newTestClock(testClockStart)
+	params := RegTestParams()
+	client := NewClient(&Client{Name: "Tester", Net: NewFakeNet(&FakeNet{}), Params: params, Clock: clock})
+	genesis, err := MakeGenesis(&Blockchain{
+		Params:           params,
+		ClientBalanceMap: map[*Client]Amount{client: 1000},
+		Timestamp:        testClockStart,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBlock(nil, client.Address, genesis, genesis.Target)
+	b.Timestamp = testClockStart.Add(params.MaxFutureDrift + time.Second)
+	for !b.HasValidProof() {
+		b.Proof++
+	}
+
+	if err := client.ProcessBlock(b); !errors.Is(err, ErrTimestampInFuture) {
+		t.Fatalf("got %v, want %v", err, ErrTimestampInFuture)
+	}
+	// the block is now exactly at the drift limit
+	clock.Set(b.Timestamp.Add(-params.MaxFutureDrift))
+	if err := client.ProcessBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	if client.LastBlock.HashVal() != b.HashVal() {
+		t.Error("client did not move to the accepted block")
+	}
+}
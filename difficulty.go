@@ -0,0 +1,102 @@
+package spartan_go
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+// Decides the proof-of-work target for the next block from the chain it
+// extends.
+type DifficultyAdjuster interface {
+	// Returns the target that a block built on top of prevBlock must use.
+	NextTarget(prevBlock *Block) *uint256.Int
+}
+
+// Keeps every block at the same target as its parent. This is the default
+// for a client.
+type FixedDifficulty struct{}
+
+func (FixedDifficulty) NextTarget(prevBlock *Block) *uint256.Int {
+	return prevBlock.Target
+}
+
+// Bitcoin-style retargeting: the target only changes on the first block of
+// each epoch of Interval blocks, scaled by how long the previous epoch took
+// compared to Interval * TargetSpacing. A single adjustment is limited to a
+// factor of MaxAdjustment in either direction, and the target never rises
+// above MaxTarget, or the chain's PowTarget if MaxTarget is nil.
+type EpochRetarget struct {
+	Interval      uint
+	TargetSpacing time.Duration
+	MaxAdjustment uint
+	MaxTarget     *uint256.Int
+}
+
+func (r *EpochRetarget) NextTarget(prevBlock *Block) *uint256.Int {
+	height := prevBlock.ChainLength + 1
+	if r.Interval == 0 || height%r.Interval != 0 {
+		return prevBlock.Target
+	}
+	first := prevBlock.Ancestor(r.Interval)
+	return retarget(prevBlock, first, r.TargetSpacing, r.MaxAdjustment, r.MaxTarget)
+}
+
+// Adjusts the target on every block, scaled by the average time between the
+// last Window blocks compared to TargetSpacing. Limits are applied as for
+// EpochRetarget.
+type MovingAverageRetarget struct {
+	Window        uint
+	TargetSpacing time.Duration
+	MaxAdjustment uint
+	MaxTarget     *uint256.Int
+}
+
+func (r *MovingAverageRetarget) NextTarget(prevBlock *Block) *uint256.Int {
+	if r.Window == 0 {
+		return prevBlock.Target
+	}
+	first := prevBlock.Ancestor(r.Window)
+	return retarget(prevBlock, first, r.TargetSpacing, r.MaxAdjustment, r.MaxTarget)
+}
+
+// Scales last.Target by the time taken to get from first to last over the
+// time that should have taken.
+func retarget(last *Block, first *Block, spacing time.Duration, maxAdjustment uint, maxTarget *uint256.Int) *uint256.Int {
+	gaps := last.ChainLength - first.ChainLength
+	if gaps == 0 || spacing <= 0 {
+		return last.Target
+	}
+	expected := int64(gaps) * int64(spacing)
+	actual := last.Timestamp.Sub(first.Timestamp).Nanoseconds()
+
+	if maxAdjustment > 1 {
+		if min := expected / int64(maxAdjustment); actual < min {
+			actual = min
+		}
+		if max := expected * int64(maxAdjustment); actual > max {
+			actual = max
+		}
+	}
+	if actual < 1 {
+		actual = 1
+	}
+
+	next := last.Target.ToBig()
+	next.Mul(next, big.NewInt(actual))
+	next.Div(next, big.NewInt(expected))
+
+	limit := last.powLimit()
+	if maxTarget != nil {
+		limit = maxTarget
+	}
+	if next.Cmp(limit.ToBig()) > 0 {
+		return limit.Clone()
+	}
+	if next.Sign() == 0 {
+		next.SetInt64(1)
+	}
+	target, _ := uint256.FromBig(next)
+	return target
+}
@@ -0,0 +1,42 @@
+package spartan_go
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// A miner that stamps its blocks as far ahead of the clock as allowed can
+// only stretch the testnet window by MaxFutureDrift, so each block's target
+// gets slightly easier at most, and never easier than PowTarget.
+func TestTestNetTargetWithFutureTimestamps(t *testing.T) {
+	params := TestNetParams()
+	adjuster := params.DifficultyAdjuster.(*MovingAverageRetarget)
+	expected := big.NewInt(int64(adjuster.Window) * int64(adjuster.TargetSpacing))
+	stretched := new(big.Int).Add(expected, big.NewInt(int64(params.MaxFutureDrift)))
+
+	clock := newTestClock(testClockStart)
+	b := NewBlock(params, "", nil, new(uint256.Int).Rsh(params.PowTarget, 8))
+	b.Timestamp = clock.Now()
+	for i := uint(1); i <= 4*adjuster.Window; i++ {
+		clock.Set(clock.Now().Add(adjuster.TargetSpacing))
+		next := NewBlock(nil, "", b, adjuster.NextTarget(b))
+		next.Timestamp = clock.Now()
+		if i > adjuster.Window {
+			next.Timestamp = next.Timestamp.Add(params.MaxFutureDrift)
+		}
+		if err := checkTimestamp(next, b, params.MedianTimeSpan, params.MaxFutureDrift, clock.Now()); err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		if next.Target.Gt(params.PowTarget) {
+			t.Fatalf("block %d: target is easier than PowTarget", i)
+		}
+		// next.Target / b.Target <= stretched / expected
+		got := new(big.Int).Mul(next.Target.ToBig(), expected)
+		if limit := new(big.Int).Mul(b.Target.ToBig(), stretched); got.Cmp(limit) > 0 {
+			t.Fatalf("block %d: target got easier by more than the drift allows", i)
+		}
+		b = next
+	}
+}
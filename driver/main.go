@@ -21,13 +21,22 @@ func main() {
 	minnie := NewMiner(&Client{Name: "Minnie", Net: fakeNet})
 	mickey := NewMiner(&Client{Name: "Mickey", Net: fakeNet})
 
+	gold := func(amount string) Amount {
+		units, err := ParseAmount(amount)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return units
+	}
+
 	genesis, err := MakeGenesis(&Blockchain{
-		ClientBalanceMap: map[*Client]uint{
-			alice:         uint(233),
-			bob:           uint(99),
-			charlie:       uint(67),
-			minnie.Client: uint(400),
-			mickey.Client: uint(300),
+		ClientBalanceMap: map[*Client]Amount{
+			alice:         gold("233 gold"),
+			bob:           gold("99 gold"),
+			charlie:       gold("67 gold"),
+			minnie.Client: gold("400 gold"),
+			mickey.Client: gold("300 gold"),
 		},
 	})
 	if err != nil {
@@ -38,12 +47,12 @@ func main() {
 	donald := NewMiner(&Client{Name: "Mickey", Net: fakeNet, StartingBlock: genesis}, 3000)
 
 	showBalances := func(client *Client) {
-		fmt.Println("Alice has " + strconv.FormatUint(uint64(client.LastBlock.BalanceOf(alice.Address)), 10) + " gold.")
-		fmt.Println("Bob has " + strconv.FormatUint(uint64(client.LastBlock.BalanceOf(bob.Address)), 10) + " gold.")
-		fmt.Println("Charlie has " + strconv.FormatUint(uint64(client.LastBlock.BalanceOf(charlie.Address)), 10) + " gold.")
-		fmt.Println("Minnie has " + strconv.FormatUint(uint64(client.LastBlock.BalanceOf(minnie.Client.Address)), 10) + " gold.")
-		fmt.Println("Mickey has " + strconv.FormatUint(uint64(client.LastBlock.BalanceOf(mickey.Client.Address)), 10) + " gold.")
-		fmt.Println("Donald has " + strconv.FormatUint(uint64(client.LastBlock.BalanceOf(donald.Client.Address)), 10) + " gold.")
+		fmt.Println("Alice has " + FormatAmount(client.LastBlock.BalanceOf(alice.Address)) + ".")
+		fmt.Println("Bob has " + FormatAmount(client.LastBlock.BalanceOf(bob.Address)) + ".")
+		fmt.Println("Charlie has " + FormatAmount(client.LastBlock.BalanceOf(charlie.Address)) + ".")
+		fmt.Println("Minnie has " + FormatAmount(client.LastBlock.BalanceOf(minnie.Client.Address)) + ".")
+		fmt.Println("Mickey has " + FormatAmount(client.LastBlock.BalanceOf(mickey.Client.Address)) + ".")
+		fmt.Println("Donald has " + FormatAmount(client.LastBlock.BalanceOf(donald.Client.Address)) + ".")
 	}
 
 	fmt.Println("Initial balances:")
@@ -56,14 +65,14 @@ func main() {
 	mickey.Initialize()
 
 	fmt.Println("Alice is transferring 40 gold to " + bob.Address)
-	alice.PostTransaction([]TxOuput{{Amount: 40, Address: bob.Address}})
+	alice.PostPayment(bob.Address, "40 gold")
 
 	fmt.Println("Charlie is transferring 40 gold to " + bob.Address)
-	charlie.PostTransaction([]TxOuput{{Amount: 40, Address: bob.Address}})
+	charlie.PostPayment(bob.Address, "40 gold")
 
 	// Invalid because Charlie has 26 gold after last transaction
 	fmt.Println("Charlie is transferring 30 gold to " + bob.Address)
-	charlie.PostTransaction([]TxOuput{{Amount: 30, Address: bob.Address}})
+	charlie.PostPayment(bob.Address, "30 gold")
 
 	time.Sleep(time.Duration(2) * time.Second)
 
@@ -0,0 +1,188 @@
+package spartan_go
+
+import (
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/holiman/uint256"
+)
+
+// Limits applied while decoding so that a malformed length prefix cannot make
+// us allocate arbitrarily large buffers. The encoder refuses to write
+// anything larger, so that everything it writes can be read back.
+const (
+	MAX_ENCODED_FIELD_LEN = 1 << 20
+	MAX_ENCODED_ITEMS     = 1 << 16
+)
+
+var (
+	ErrMalformedEncoding = errors.New("Malformed binary encoding")
+	ErrEncodingTooLarge  = errors.New("Value is too large for the binary encoding")
+)
+
+// Integers are written big-endian with a fixed width, and variable length
+// fields are prefixed with their length as a big-endian uint32. Like the
+// decoder, the encoder remembers the first error it hits, so a structure can
+// be written whole and err checked once at the end.
+type encoder struct {
+	buf []byte
+	err error
+}
+
+func (e *encoder) writeByte(v byte) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) writeUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) writeUint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+// A nil value is written as zero.
+func (e *encoder) writeUint256(v *uint256.Int) {
+	if v == nil {
+		v = new(uint256.Int)
+	}
+	b := v.Bytes32()
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) writeBytes(v []byte) {
+	if len(v) > MAX_ENCODED_FIELD_LEN && e.err == nil {
+		e.err = ErrEncodingTooLarge
+	}
+	e.writeUint32(uint32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// The number of items in a list that follows.
+func (e *encoder) writeCount(n int) {
+	if n > MAX_ENCODED_ITEMS && e.err == nil {
+		e.err = ErrEncodingTooLarge
+	}
+	e.writeUint32(uint32(n))
+}
+
+func (e *encoder) writeString(v string) {
+	e.writeBytes([]byte(v))
+}
+
+// A public key is encoded as its modulus (minimal big-endian bytes) followed
+// by its exponent as a uint64.
+func (e *encoder) writePublicKey(pubKey rsa.PublicKey) {
+	if pubKey.N == nil {
+		e.writeBytes(nil)
+	} else {
+		e.writeBytes(pubKey.N.Bytes())
+	}
+	e.writeUint64(uint64(pubKey.E))
+}
+
+// The decoder remembers the first error it hits, so callers can read a whole
+// structure and check err once at the end.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = ErrMalformedEncoding
+		return nil
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) readByte() byte {
+	b := d.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) readUint32() uint32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) readUint64() uint64 {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (d *decoder) readUint256() *uint256.Int {
+	b := d.take(32)
+	if b == nil {
+		return new(uint256.Int)
+	}
+	return new(uint256.Int).SetBytes32(b)
+}
+
+func (d *decoder) readBytes() []byte {
+	n := d.readUint32()
+	if n > MAX_ENCODED_FIELD_LEN {
+		d.err = ErrMalformedEncoding
+		return nil
+	}
+	b := d.take(int(n))
+	if b == nil {
+		return nil
+	}
+	v := make([]byte, len(b))
+	copy(v, b)
+	return v
+}
+
+func (d *decoder) readString() string {
+	return string(d.readBytes())
+}
+
+func (d *decoder) readCount() int {
+	n := d.readUint32()
+	if n > MAX_ENCODED_ITEMS {
+		d.err = ErrMalformedEncoding
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) readPublicKey() rsa.PublicKey {
+	n := d.readBytes()
+	e := d.readUint64()
+	if d.err != nil {
+		return rsa.PublicKey{}
+	}
+	if e > 1<<31-1 {
+		d.err = ErrMalformedEncoding
+		return rsa.PublicKey{}
+	}
+	return rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(e)}
+}
+
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		d.err = ErrMalformedEncoding
+	}
+	return d.err
+}
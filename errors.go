@@ -0,0 +1,82 @@
+package spartan_go
+
+import (
+	"errors"
+)
+
+// Reasons a transaction can be rejected. They are returned wrapped in a
+// *TxError, so use errors.Is to check for them.
+var (
+	ErrDuplicateTransaction = errors.New("Duplicate transaction")
+	ErrUnsignedTransaction  = errors.New("Transaction is not signed")
+	ErrInvalidSignature     = errors.New("Invalid signature")
+	ErrInsufficientFunds    = errors.New("Insufficient gold")
+	ErrNonceTooLow          = errors.New("Nonce too low, transaction was replayed")
+	ErrNonceGap             = errors.New("Nonce too high, transaction is out of order")
+	ErrNonceInUse           = errors.New("Another pending transaction already uses this nonce")
+	ErrFeeBumpTooLow        = errors.New("Replacement fee is not enough higher than the fee of the pending transaction")
+	ErrNotPending           = errors.New("Transaction is not pending")
+	ErrMempoolFull          = errors.New("Mempool is full and the fee is too low to evict another transaction")
+	ErrNoChain              = errors.New("No chain to check the transaction against")
+	ErrFeeRateTooLow        = errors.New("Fee rate is below the minimum relay fee rate")
+	ErrBlockFull            = errors.New("Transaction does not fit in the block")
+)
+
+// Reasons a block can be rejected. They are returned wrapped in a
+// *BlockError, so use errors.Is to check for them.
+var (
+	ErrDuplicateBlock    = errors.New("Block already received")
+	ErrUnknownGenesis    = errors.New("Block claims height 0 but is not the chain's genesis block")
+	ErrInvalidProof      = errors.New("Block does not have a valid proof")
+	ErrBadMerkleRoot     = errors.New("Block transactions do not match its Merkle root")
+	ErrBadStateRoot      = errors.New("Block balances do not match its state root")
+	ErrUnknownParent     = errors.New("Block parent is unknown")
+	ErrBadHeight         = errors.New("Block height is not one more than its parent's")
+	ErrBadTarget         = errors.New("Block target does not match the expected target")
+	ErrBadCoinbase       = errors.New("Block coinbase reward does not match the block subsidy")
+	ErrBadRewardShares   = errors.New("Block reward shares are invalid")
+	ErrBlockTooHeavy     = errors.New("Block transactions weigh more than the maximum block weight")
+	ErrTimestampTooOld   = errors.New("Block timestamp is not after the median of the previous blocks")
+	ErrTimestampInFuture = errors.New("Block timestamp is too far in the future")
+)
+
+type TxError struct {
+	TxId string
+	Err  error
+}
+
+func (e *TxError) Error() string {
+	return "Transaction " + e.TxId + " rejected: " + e.Err.Error()
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// An address in a transaction or block that is not valid or is for another
+// network. Err is the error ParseAddress returned for it.
+type AddressError struct {
+	Address string
+	Err     error
+}
+
+func (e *AddressError) Error() string {
+	return "Address " + e.Address + " rejected: " + e.Err.Error()
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+type BlockError struct {
+	Hash string
+	Err  error
+}
+
+func (e *BlockError) Error() string {
+	return "Block " + e.Hash + " rejected: " + e.Err.Error()
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
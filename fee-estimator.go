@@ -0,0 +1,126 @@
+package spartan_go
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	FEE_ESTIMATE_WINDOW       = uint(20)
+	FEE_ESTIMATE_CONFIDENCE   = 0.95
+	DEFAULT_FEE_TARGET_BLOCKS = uint(2)
+)
+
+// Estimates the fee a transaction needs to be confirmed within a number of
+// blocks, from the fee rates of the last Window blocks and of the
+// transactions waiting in the mempool. Estimated fee rates never go below
+// MinFeeRate, the relay minimum. Until there are blocks past genesis to learn
+// from, estimated fees are raised to FallbackFee.
+type FeeEstimator struct {
+	Window      uint
+	FallbackFee Amount
+	MinFeeRate  Amount
+}
+
+func NewFeeEstimator(cfg *FeeEstimator) *FeeEstimator {
+	estimator := &FeeEstimator{
+		Window:      cfg.Window,
+		FallbackFee: cfg.FallbackFee,
+		MinFeeRate:  cfg.MinFeeRate,
+	}
+	if estimator.Window == 0 {
+		estimator.Window = FEE_ESTIMATE_WINDOW
+	}
+	return estimator
+}
+
+// The fee rate needed for a transaction posted now to be included within the
+// next blocks blocks after tip.
+//
+// The lowest fee rate each full recent block accepted (zero for a block with
+// room to spare) says how likely a fee rate is to get into one block, and the
+// estimate is the lowest fee rate that gets into at least one of the next
+// blocks blocks with FEE_ESTIMATE_CONFIDENCE if blocks accept fee rates like
+// the recent ones did. It is raised if the mempool already holds more weight
+// paying higher rates than those blocks can include.
+func (e *FeeEstimator) EstimateFeeRate(tip *Block, mempool *Mempool, blocks uint) Amount {
+	feeRate, _ := e.estimateFeeRate(tip, mempool, blocks)
+	return feeRate
+}
+
+// Also reports whether there were any blocks to estimate from.
+func (e *FeeEstimator) estimateFeeRate(tip *Block, mempool *Mempool, blocks uint) (Amount, bool) {
+	if blocks == 0 {
+		blocks = 1
+	}
+	if tip == nil {
+		return e.MinFeeRate, false
+	}
+
+	minFeeRates := make([]Amount, 0, e.Window)
+	for block := tip; block != nil && !block.IsGenesisBlock() && uint(len(minFeeRates)) < e.Window; block = block.PrevBlock {
+		minFeeRates = append(minFeeRates, blockMinFeeRate(block))
+	}
+
+	feeRate := e.MinFeeRate
+	if len(minFeeRates) > 0 {
+		sort.Slice(minFeeRates, func(i, j int) bool {
+			return minFeeRates[i] < minFeeRates[j]
+		})
+		perBlock := 1 - math.Pow(1-FEE_ESTIMATE_CONFIDENCE, 1/float64(blocks))
+		i := int(math.Ceil(perBlock*float64(len(minFeeRates)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		if minFeeRates[i] > feeRate {
+			feeRate = minFeeRates[i]
+		}
+	}
+
+	if mempool != nil && tip.maxWeight() != ^uint(0) {
+		room := Amount(tip.maxWeight()) * Amount(blocks)
+		ahead := Amount(0)
+		for _, tx := range mempool.Select() {
+			if ahead += Amount(tx.Weight()); ahead > room {
+				if outbid := addCapped(tx.FeeRate(), 1); outbid > feeRate {
+					feeRate = outbid
+				}
+				break
+			}
+		}
+	}
+	return feeRate, len(minFeeRates) > 0
+}
+
+// The fee needed for a transaction of the given weight to be included within
+// the next blocks blocks after tip.
+func (e *FeeEstimator) EstimateFee(tip *Block, mempool *Mempool, blocks uint, weight uint) Amount {
+	feeRate, fromBlocks := e.estimateFeeRate(tip, mempool, blocks)
+	fee := FeeForWeight(feeRate, weight)
+	if !fromBlocks && fee < e.FallbackFee {
+		return e.FallbackFee
+	}
+	return fee
+}
+
+// The lowest fee rate the block accepted if it was full, that is if it did not
+// have room for another transaction as heavy as its heaviest one, and zero
+// otherwise.
+func blockMinFeeRate(b *Block) Amount {
+	heaviest := uint(0)
+	for _, tx := range b.Transactions {
+		if tx.Weight() > heaviest {
+			heaviest = tx.Weight()
+		}
+	}
+	if len(b.Transactions) == 0 || b.Weight()+heaviest <= b.maxWeight() {
+		return 0
+	}
+	feeRate := b.Transactions[0].FeeRate()
+	for _, tx := range b.Transactions[1:] {
+		if tx.FeeRate() < feeRate {
+			feeRate = tx.FeeRate()
+		}
+	}
+	return feeRate
+}
@@ -0,0 +1,119 @@
+package spartan_go
+
+import (
+	"testing"
+	"time"
+)
+
+func feeTestTx(sender *Client, nonce uint, fee Amount) *Transaction {
+	tx := &Transaction{
+		Fee:     fee,
+		From:    sender.Address,
+		Nonce:   nonce,
+		PubKey:  sender.key.PublicKey,
+		Outputs: []TxOuput{{Amount: 1, Address: sender.Address}},
+	}
+	tx.Sign(sender.key)
+	return tx
+}
+
+// A regtest client whose blocks hold at most two of its transactions.
+func newFeeTestClient(t *testing.T) (*Client, uint) {
+	params := RegTestParams()
+	client := NewClient(&Client{Name: "Tester", Net: NewFakeNet(&FakeNet{}), Params: params})
+	txWeight := feeTestTx(client, 0, 0).Weight()
+	params.MaxBlockWeight = 2 * txWeight
+	_, err := MakeGenesis(&Blockchain{
+		Params:           params,
+		ClientBalanceMap: map[*Client]Amount{client: 1000 * params.DefaultTxFee},
+		Timestamp:        time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, txWeight
+}
+
+// Extends the client's chain with a block for each list of fees, holding one
+// transaction per fee.
+func extendFeeTestChain(t *testing.T, client *Client, blockFees ...[]Amount) {
+	for _, fees := range blockFees {
+		tip := client.LastBlock
+		b := NewBlock(nil, client.Address, tip, tip.Target)
+		for _, fee := range fees {
+			if err := b.addTransaction(feeTestTx(client, b.NextNonce(client.Address), fee)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		client.LastBlock = b
+	}
+}
+
+func TestEstimateFeeFallsBackWithoutBlocks(t *testing.T) {
+	client, txWeight := newFeeTestClient(t)
+	if fee := client.EstimateFee(DEFAULT_FEE_TARGET_BLOCKS); fee != client.Params.DefaultTxFee {
+		t.Errorf("fee on a new chain %d, want the default fee %d", fee, client.Params.DefaultTxFee)
+	}
+
+	estimator := NewFeeEstimator(&FeeEstimator{MinFeeRate: client.Params.MinRelayFeeRate})
+	relayFee := FeeForWeight(client.Params.MinRelayFeeRate, txWeight)
+	if fee := estimator.EstimateFee(client.LastBlock, nil, DEFAULT_FEE_TARGET_BLOCKS, txWeight); fee != relayFee {
+		t.Errorf("fee without a fallback %d, want the relay minimum %d", fee, relayFee)
+	}
+}
+
+// Once there are blocks to learn from, a quiet chain only asks for the relay
+// minimum, not the default fee.
+func TestEstimateFeeFloorIsRelayMinimum(t *testing.T) {
+	client, txWeight := newFeeTestClient(t)
+	extendFeeTestChain(t, client, []Amount{}, []Amount{FeeForWeight(client.Params.MinRelayFeeRate, txWeight)})
+
+	relayFee := FeeForWeight(client.Params.MinRelayFeeRate, txWeight)
+	fee := client.EstimateFee(DEFAULT_FEE_TARGET_BLOCKS, txWeight)
+	if fee != relayFee {
+		t.Errorf("fee %d, want the relay minimum %d", fee, relayFee)
+	}
+	if fee >= client.Params.DefaultTxFee {
+		t.Errorf("fee %d is not below the default fee %d", fee, client.Params.DefaultTxFee)
+	}
+	client.Mempool.SetTip(client.LastBlock)
+	if err := client.Mempool.Add(feeTestTx(client, client.LastBlock.NextNonce(client.Address), fee)); err != nil {
+		t.Errorf("mempool refused a transaction paying the estimate: %v", err)
+	}
+}
+
+func TestEstimateFeeFollowsFullBlocks(t *testing.T) {
+	client, txWeight := newFeeTestClient(t)
+	relayFee := FeeForWeight(client.Params.MinRelayFeeRate, txWeight)
+	blockFees := make([][]Amount, FEE_ESTIMATE_WINDOW)
+	for i := range blockFees {
+		blockFees[i] = []Amount{100 * relayFee, 10 * relayFee}
+	}
+	extendFeeTestChain(t, client, blockFees...)
+
+	want := FeeForWeight(feeTestTx(client, 0, 10*relayFee).FeeRate(), txWeight)
+	if fee := client.EstimateFee(DEFAULT_FEE_TARGET_BLOCKS, txWeight); fee != want {
+		t.Errorf("fee %d, want %d, the lowest fee the full blocks accepted", fee, want)
+	}
+}
+
+func TestEstimateFeeOutbidsMempool(t *testing.T) {
+	client, txWeight := newFeeTestClient(t)
+	extendFeeTestChain(t, client, []Amount{})
+	client.Mempool.SetTip(client.LastBlock)
+
+	// three transactions waiting for a block with room for two
+	relayFee := FeeForWeight(client.Params.MinRelayFeeRate, txWeight)
+	for nonce, fee := range []Amount{30 * relayFee, 20 * relayFee, 10 * relayFee} {
+		if err := client.Mempool.Add(feeTestTx(client, uint(nonce), fee)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := feeTestTx(client, 0, 10*relayFee).FeeRate() + 1
+	if feeRate := client.EstimateFeeRate(1); feeRate != want {
+		t.Errorf("fee rate %d, want %d to outbid the transaction left over", feeRate, want)
+	}
+	if feeRate := client.EstimateFeeRate(2); feeRate != client.Params.MinRelayFeeRate {
+		t.Errorf("fee rate within two blocks %d, want the relay minimum %d", feeRate, client.Params.MinRelayFeeRate)
+	}
+}
@@ -0,0 +1,24 @@
+package spartan_go
+
+// Decides which of two valid chain tips a client should follow.
+type ForkChoice interface {
+	// Reports whether the chain ending in candidate should replace the chain
+	// ending in current.
+	Prefer(candidate *Block, current *Block) bool
+}
+
+// Follows the chain with the most cumulative proof-of-work. On a tie the
+// tip that was seen first is kept. This is the default for a client.
+type MostWorkForkChoice struct{}
+
+func (MostWorkForkChoice) Prefer(candidate *Block, current *Block) bool {
+	return candidate.ChainWork().Gt(current.ChainWork())
+}
+
+// Follows the chain with the most blocks regardless of their targets, which
+// is how clients chose between forks before chain work was tracked.
+type LongestChainForkChoice struct{}
+
+func (LongestChainForkChoice) Prefer(candidate *Block, current *Block) bool {
+	return candidate.ChainLength > current.ChainLength
+}
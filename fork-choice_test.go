@@ -0,0 +1,200 @@
+package spartan_go
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+// Uses the target set for a parent block's hash, and otherwise keeps the
+// parent's target, so that tests can build forks of different difficulty
+// that a client still accepts.
+type scriptedDifficulty struct {
+	targets map[string]*uint256.Int
+}
+
+func (d *scriptedDifficulty) NextTarget(prevBlock *Block) *uint256.Int {
+	if target, ok := d.targets[prevBlock.HashVal()]; ok {
+		return target
+	}
+	return prevBlock.Target
+}
+
+// A regtest client with its own genesis block and a difficulty adjuster the
+// test can script.
+func newForkTestClient(t *testing.T, forkChoice ForkChoice) (*Client, *scriptedDifficulty) {
+	difficulty := &scriptedDifficulty{targets: make(map[string]*uint256.Int)}
+	params := RegTestParams()
+	params.DifficultyAdjuster = difficulty
+	client := NewClient(&Client{Name: "Tester", Net: NewFakeNet(&FakeNet{}), Params: params, ForkChoice: forkChoice})
+	_, err := MakeGenesis(&Blockchain{
+		Params:           params,
+		ClientBalanceMap: map[*Client]Amount{client: 1000},
+		Timestamp:        time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, difficulty
+}
+
+// Builds and mines an empty block on prevBlock. The extra data keeps sibling
+// blocks with the same target apart.
+func mineTestBlock(client *Client, prevBlock *Block, target *uint256.Int, extraData string) *Block {
+	b := NewBlock(nil, client.Address, prevBlock, target)
+	b.Timestamp = prevBlock.Timestamp.Add(time.Minute)
+	b.ExtraData = extraData
+	for !b.HasValidProof() {
+		b.Proof++
+	}
+	return b
+}
+
+func processTestBlocks(t *testing.T, client *Client, blocks ...*Block) {
+	for _, b := range blocks {
+		if err := client.ProcessBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMostWorkForkChoicePrefer(t *testing.T) {
+	genesis := NewBlock(RegTestParams(), "", nil, TargetWithLeadingZeroes(1))
+	easy := NewBlock(nil, "", genesis, TargetWithLeadingZeroes(1))
+	easyToo := NewBlock(nil, "", genesis, TargetWithLeadingZeroes(1))
+	hard := NewBlock(nil, "", genesis, TargetWithLeadingZeroes(8))
+	longer := NewBlock(nil, "", easy, TargetWithLeadingZeroes(1))
+
+	choice := MostWorkForkChoice{}
+	if !choice.Prefer(hard, easy) {
+		t.Error("harder block not preferred over easier sibling")
+	}
+	if choice.Prefer(easy, hard) {
+		t.Error("easier block preferred over harder sibling")
+	}
+	if choice.Prefer(easyToo, easy) || choice.Prefer(easy, easyToo) {
+		t.Error("equal work should keep the current tip")
+	}
+	if !choice.Prefer(hard, longer) {
+		t.Error("two easy blocks preferred over one much harder block")
+	}
+	if !(LongestChainForkChoice{}).Prefer(longer, hard) {
+		t.Error("longest chain rule did not prefer the longer chain")
+	}
+}
+
+func TestReceiveBlockFollowsMostWork(t *testing.T) {
+	client, difficulty := newForkTestClient(t, MostWorkForkChoice{})
+	genesis := client.LastBlock
+	easy := genesis.Target
+	hard := new(uint256.Int).Rsh(easy, 8)
+
+	a1 := mineTestBlock(client, genesis, easy, "a")
+	a2 := mineTestBlock(client, a1, easy, "a")
+	processTestBlocks(t, client, a1, a2)
+	if client.LastBlock.HashVal() != a2.HashVal() {
+		t.Fatal("client did not follow the only chain")
+	}
+
+	difficulty.targets[genesis.HashVal()] = hard
+	b1 := mineTestBlock(client, genesis, hard, "b")
+	processTestBlocks(t, client, b1)
+	if client.LastBlock.HashVal() != b1.HashVal() {
+		t.Errorf("client stayed on the longer chain with less work, at height %d", client.LastBlock.ChainLength)
+	}
+	if !b1.ChainWork().Gt(a2.ChainWork()) {
+		t.Error("harder fork should have more chain work")
+	}
+}
+
+func TestReceiveBlockLongestChainIgnoresDifficulty(t *testing.T) {
+	client, difficulty := newForkTestClient(t, LongestChainForkChoice{})
+	genesis := client.LastBlock
+	easy := genesis.Target
+	hard := new(uint256.Int).Rsh(easy, 8)
+
+	a1 := mineTestBlock(client, genesis, easy, "a")
+	a2 := mineTestBlock(client, a1, easy, "a")
+	processTestBlocks(t, client, a1, a2)
+
+	difficulty.targets[genesis.HashVal()] = hard
+	b1 := mineTestBlock(client, genesis, hard, "b")
+	processTestBlocks(t, client, b1)
+	if client.LastBlock.HashVal() != a2.HashVal() {
+		t.Error("longest chain rule switched to a shorter chain")
+	}
+}
+
+func TestReceiveBlockKeepsFirstSeenOnTie(t *testing.T) {
+	for _, order := range []string{"a first", "b first"} {
+		client, _ := newForkTestClient(t, MostWorkForkChoice{})
+		genesis := client.LastBlock
+		a1 := mineTestBlock(client, genesis, genesis.Target, "a")
+		b1 := mineTestBlock(client, genesis, genesis.Target, "b")
+
+		first, second := a1, b1
+		if order == "b first" {
+			first, second = b1, a1
+		}
+		processTestBlocks(t, client, first, second)
+		if client.LastBlock.HashVal() != first.HashVal() {
+			t.Errorf("%s: tip moved to the block seen second", order)
+		}
+
+		// extending the other fork breaks the tie
+		next := mineTestBlock(client, second, genesis.Target, "next")
+		processTestBlocks(t, client, next)
+		if client.LastBlock.HashVal() != next.HashVal() {
+			t.Errorf("%s: tip did not move to the heavier fork", order)
+		}
+	}
+}
+
+// A block at height 0 skips the proof and consensus checks, so a forged one
+// with a tiny target must not win the fork choice on its claimed work.
+func TestReceiveBlockRejectsForgedGenesis(t *testing.T) {
+	client, _ := newForkTestClient(t, MostWorkForkChoice{})
+	genesis := client.LastBlock
+	a1 := mineTestBlock(client, genesis, genesis.Target, "a")
+	processTestBlocks(t, client, a1)
+
+	thief := NewClient(&Client{Name: "Thief", Net: NewFakeNet(&FakeNet{}), Params: client.Params})
+	forged, err := MakeGenesis(&Blockchain{
+		Params:           client.Params,
+		StartingBalances: map[string]Amount{thief.Address: 1 << 60},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Target = uint256.NewInt(1)
+	forged.setChainWork(nil)
+	if !forged.ChainWork().Gt(a1.ChainWork()) {
+		t.Fatal("forged genesis should claim more work than the chain")
+	}
+
+	if err := client.ProcessBlock(forged); !errors.Is(err, ErrUnknownGenesis) {
+		t.Errorf("got %v, want %v", err, ErrUnknownGenesis)
+	}
+	if client.LastBlock.HashVal() != a1.HashVal() {
+		t.Error("client moved to the forged genesis block")
+	}
+	if err := client.ProcessBlock(genesis); !errors.Is(err, ErrDuplicateBlock) {
+		t.Errorf("own genesis: got %v, want %v", err, ErrDuplicateBlock)
+	}
+
+	data, err := forged.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeserializeBlock(data, genesis.HashVal()); !errors.Is(err, ErrUnknownGenesis) {
+		t.Errorf("deserializing forged genesis: got %v, want %v", err, ErrUnknownGenesis)
+	}
+	if data, err = genesis.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeserializeBlock(data, genesis.HashVal()); err != nil {
+		t.Errorf("deserializing own genesis: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package spartan_go
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+// Describes a chain in a genesis.json file. The network selects the preset
+// parameters that the remaining fields override. Loading the same file on
+// any machine yields the same genesis block hash. Balances, rewards and fees
+// are in base units.
+type GenesisConfig struct {
+	Network          string            `json:"network"`
+	Balances         map[string]Amount `json:"balances"`
+	Target           string            `json:"target"`
+	AmountDecimals   *uint             `json:"amountDecimals,omitempty"`
+	CoinbaseReward   *Amount           `json:"coinbaseReward"`
+	HalvingInterval  *uint             `json:"halvingInterval,omitempty"`
+	MaxSupply        *Amount           `json:"maxSupply,omitempty"`
+	CoinbaseMaturity *uint             `json:"coinbaseMaturity,omitempty"`
+	ConfirmedDepth   *uint             `json:"confirmedDepth"`
+	DefaultTxFee     *Amount           `json:"defaultTxFee"`
+	MaxBlockWeight   *uint             `json:"maxBlockWeight,omitempty"`
+	MinRelayFeeRate  *Amount           `json:"minRelayFeeRate,omitempty"`
+	Timestamp        time.Time         `json:"timestamp"`
+	ExtraData        string            `json:"extraData,omitempty"`
+}
+
+// Reports which field of a genesis config is invalid, and why.
+type GenesisConfigError struct {
+	Field  string
+	Reason string
+}
+
+func (e *GenesisConfigError) Error() string {
+	return "Invalid genesis config field " + e.Field + ": " + e.Reason
+}
+
+// Decodes and validates a genesis config. Unknown fields are rejected so that
+// a misspelled field is not silently ignored.
+func ParseGenesisConfig(data []byte) (*GenesisConfig, error) {
+	cfg := &GenesisConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, &GenesisConfigError{Field: "(file)", Reason: err.Error()}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func LoadGenesisConfig(path string) (*GenesisConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGenesisConfig(data)
+}
+
+func (cfg *GenesisConfig) Validate() error {
+	network, err := ParseNetwork(cfg.Network)
+	if err != nil {
+		return &GenesisConfigError{Field: "network", Reason: "unknown network \"" + cfg.Network + "\""}
+	}
+	if len(cfg.Balances) == 0 {
+		return &GenesisConfigError{Field: "balances", Reason: "at least one starting balance is required"}
+	}
+	total := Amount(0)
+	for addr, balance := range cfg.Balances {
+		if _, _, err := ParseAddress(addr, network); err != nil {
+			return &GenesisConfigError{Field: "balances", Reason: addr + ": " + err.Error()}
+		}
+		if total, err = total.Add(balance); err != nil {
+			return &GenesisConfigError{Field: "balances", Reason: "total starting balance overflows"}
+		}
+	}
+	if target, err := uint256.FromHex(cfg.Target); err != nil || target.IsZero() {
+		return &GenesisConfigError{Field: "target", Reason: "must be a non-zero hex number such as 0x1fff"}
+	}
+	if cfg.AmountDecimals != nil && *cfg.AmountDecimals > MAX_AMOUNT_DECIMALS {
+		return &GenesisConfigError{Field: "amountDecimals", Reason: "must be at most " + strconv.Itoa(int(MAX_AMOUNT_DECIMALS))}
+	}
+	if cfg.CoinbaseReward == nil {
+		return &GenesisConfigError{Field: "coinbaseReward", Reason: "missing"}
+	}
+	if cfg.ConfirmedDepth == nil || *cfg.ConfirmedDepth == 0 {
+		return &GenesisConfigError{Field: "confirmedDepth", Reason: "must be at least 1"}
+	}
+	if cfg.DefaultTxFee == nil {
+		return &GenesisConfigError{Field: "defaultTxFee", Reason: "missing"}
+	}
+	if cfg.Timestamp.IsZero() {
+		return &GenesisConfigError{Field: "timestamp", Reason: "missing"}
+	}
+	return nil
+}
+
+// The chain parameters described by the config. The config must be valid.
+func (cfg *GenesisConfig) Params() *ChainParams {
+	network, _ := ParseNetwork(cfg.Network)
+	params := ParamsForNetwork(network)
+	params.PowTarget, _ = uint256.FromHex(cfg.Target)
+	if cfg.AmountDecimals != nil {
+		params.AmountDecimals = *cfg.AmountDecimals
+	}
+	params.CoinbaseReward = *cfg.CoinbaseReward
+	if cfg.HalvingInterval != nil {
+		params.HalvingInterval = *cfg.HalvingInterval
+	}
+	if cfg.MaxSupply != nil {
+		params.MaxSupply = *cfg.MaxSupply
+	}
+	if cfg.CoinbaseMaturity != nil {
+		params.CoinbaseMaturity = *cfg.CoinbaseMaturity
+	}
+	params.ConfirmedDepth = *cfg.ConfirmedDepth
+	params.DefaultTxFee = *cfg.DefaultTxFee
+	if cfg.MaxBlockWeight != nil {
+		params.MaxBlockWeight = *cfg.MaxBlockWeight
+	}
+	if cfg.MinRelayFeeRate != nil {
+		params.MinRelayFeeRate = *cfg.MinRelayFeeRate
+	}
+	return params
+}
+
+// Builds the genesis block and the chain parameters it uses.
+func (cfg *GenesisConfig) MakeGenesis() (*Block, *ChainParams, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	params := cfg.Params()
+	g, err := MakeGenesis(&Blockchain{
+		StartingBalances: cfg.Balances,
+		Params:           params,
+		Timestamp:        cfg.Timestamp,
+		ExtraData:        cfg.ExtraData,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, params, nil
+}
+
+// Describes an existing chain, so that it can be written back out as a
+// genesis.json file.
+func ExportGenesisConfig(params *ChainParams, genesis *Block) *GenesisConfig {
+	amountDecimals := params.AmountDecimals
+	coinbaseReward := params.CoinbaseReward
+	halvingInterval := params.HalvingInterval
+	maxSupply := params.MaxSupply
+	coinbaseMaturity := params.CoinbaseMaturity
+	confirmedDepth := params.ConfirmedDepth
+	defaultTxFee := params.DefaultTxFee
+	maxBlockWeight := params.MaxBlockWeight
+	minRelayFeeRate := params.MinRelayFeeRate
+	return &GenesisConfig{
+		Network:          params.Network.String(),
+		Balances:         genesis.Balances(),
+		Target:           genesis.Target.Hex(),
+		AmountDecimals:   &amountDecimals,
+		CoinbaseReward:   &coinbaseReward,
+		HalvingInterval:  &halvingInterval,
+		MaxSupply:        &maxSupply,
+		CoinbaseMaturity: &coinbaseMaturity,
+		ConfirmedDepth:   &confirmedDepth,
+		DefaultTxFee:     &defaultTxFee,
+		MaxBlockWeight:   &maxBlockWeight,
+		MinRelayFeeRate:  &minRelayFeeRate,
+		Timestamp:        genesis.Timestamp.UTC(),
+		ExtraData:        genesis.ExtraData,
+	}
+}
+
+func (cfg *GenesisConfig) WriteFile(path string) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
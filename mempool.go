@@ -0,0 +1,542 @@
+package spartan_go
+
+import (
+	"container/heap"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MEMPOOL_MAX_SIZE              = 1 << 20
+	MEMPOOL_EXPIRY                = 2 * time.Hour
+	MEMPOOL_MAX_QUEUED_PER_SENDER = 16
+	MEMPOOL_MIN_FEE_BUMP_PERCENT  = 10
+)
+
+type mempoolEntry struct {
+	tx     *Transaction
+	id     string
+	size   int
+	added  time.Time
+	queued bool
+}
+
+// The pending transactions a client knows about, checked against the tip of
+// its chain. Each sender's transactions form a run of consecutive nonces
+// starting at the sender's next nonce as of the tip, so that all of them can
+// go into the next block.
+//
+// A transaction whose nonce is ahead of that run is queued instead, as long
+// as it is no more than MaxQueuedPerSender nonces ahead and the sender's
+// balance covers it along with the sender's other pending and queued
+// transactions, and is promoted once the transactions before it arrive.
+//
+// A pending or queued transaction is replaced by another one from the same
+// sender with the same nonce if its fee is at least MinFeeBumpPercent higher,
+// and always by at least one base unit.
+//
+// Transactions must pay at least MinRelayFeeRate per FEE_RATE_WEIGHT weight
+// units. MaxSize caps the total weight of the pool, which is its encoded size
+// in bytes; when it is exceeded the transaction with the lowest fee rate is
+// evicted. Transactions expire after Expiry.
+type Mempool struct {
+	MaxSize            int
+	Expiry             time.Duration
+	MaxQueuedPerSender int
+	MinFeeBumpPercent  uint
+	MinRelayFeeRate    Amount
+	Clock              Clock
+	tip                *Block
+	entries            map[string]*mempoolEntry
+	senders            map[string][]*mempoolEntry
+	queued             map[string]map[uint]*mempoolEntry
+	size               int
+	lock               sync.Mutex
+}
+
+func NewMempool(cfg *Mempool) *Mempool {
+	mp := &Mempool{
+		MaxSize:            cfg.MaxSize,
+		Expiry:             cfg.Expiry,
+		MaxQueuedPerSender: cfg.MaxQueuedPerSender,
+		MinFeeBumpPercent:  cfg.MinFeeBumpPercent,
+		MinRelayFeeRate:    cfg.MinRelayFeeRate,
+		Clock:              cfg.Clock,
+		entries:            make(map[string]*mempoolEntry),
+		senders:            make(map[string][]*mempoolEntry),
+		queued:             make(map[string]map[uint]*mempoolEntry),
+	}
+	if mp.MaxSize == 0 {
+		mp.MaxSize = MEMPOOL_MAX_SIZE
+	}
+	if mp.Expiry == 0 {
+		mp.Expiry = MEMPOOL_EXPIRY
+	}
+	if mp.MaxQueuedPerSender == 0 {
+		mp.MaxQueuedPerSender = MEMPOOL_MAX_QUEUED_PER_SENDER
+	}
+	if mp.MinFeeBumpPercent == 0 {
+		mp.MinFeeBumpPercent = MEMPOOL_MIN_FEE_BUMP_PERCENT
+	}
+	if mp.Clock == nil {
+		mp.Clock = SystemClock{}
+	}
+	return mp
+}
+
+// The number of transactions in the pool, including queued ones.
+func (mp *Mempool) Len() int {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return len(mp.entries)
+}
+
+// The number of transactions waiting for an earlier nonce.
+func (mp *Mempool) QueuedLen() int {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	count := 0
+	for _, queue := range mp.queued {
+		count += len(queue)
+	}
+	return count
+}
+
+// The total weight of the pooled transactions.
+func (mp *Mempool) Size() int {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.size
+}
+
+func (mp *Mempool) Has(txId string) bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	_, ok := mp.entries[txId]
+	return ok
+}
+
+func (mp *Mempool) Get(txId string) *Transaction {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if entry, ok := mp.entries[txId]; ok {
+		return entry.tx
+	}
+	return nil
+}
+
+// Validates tx against the tip and the sender's other pending transactions
+// and adds it to the pool, or queues it if its nonce is ahead of the sender's
+// pending transactions. A transaction with the same nonce as one already in
+// the pool replaces it if it pays enough more. The returned error is a
+// *TxError.
+func (mp *Mempool) Add(tx *Transaction) error {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.expire()
+	return mp.add(tx, mp.Clock.Now())
+}
+
+func (mp *Mempool) add(tx *Transaction, added time.Time) error {
+	id := tx.Id()
+	if err := mp.checkTransaction(tx, id); err != nil {
+		return &TxError{TxId: id, Err: err}
+	}
+	return mp.addChecked(&mempoolEntry{tx: tx, id: id, size: int(tx.Weight()), added: added})
+}
+
+// Adds an entry whose transaction already passed checkTransaction, so only
+// the checks that depend on the tip and the rest of the pool are run.
+func (mp *Mempool) addChecked(entry *mempoolEntry) error {
+	tx, id := entry.tx, entry.id
+	err := mp.checkAgainstTip(tx)
+	if err == ErrNonceGap {
+		err = mp.queue(entry)
+	} else if err == ErrNonceInUse {
+		err = mp.replace(entry)
+	} else if err == nil {
+		mp.insert(entry)
+		mp.promote(tx.From)
+	}
+	if err != nil {
+		return &TxError{TxId: id, Err: err}
+	}
+
+	for mp.size > mp.MaxSize {
+		if mp.evict() == entry {
+			return &TxError{TxId: id, Err: ErrMempoolFull}
+		}
+	}
+	return nil
+}
+
+func (mp *Mempool) insert(entry *mempoolEntry) {
+	entry.queued = false
+	mp.entries[entry.id] = entry
+	mp.senders[entry.tx.From] = append(mp.senders[entry.tx.From], entry)
+	mp.size += entry.size
+}
+
+func (mp *Mempool) queue(entry *mempoolEntry) error {
+	from, nonce := entry.tx.From, entry.tx.Nonce
+	if nonce-mp.nextNonce(from) > uint(mp.MaxQueuedPerSender) {
+		return ErrNonceGap
+	}
+
+	// the sender must be able to afford everything it has pending and queued,
+	// not counting a queued transaction this one replaces
+	spent, err := entry.tx.TotalOutput()
+	if err != nil {
+		return err
+	}
+	for _, other := range mp.senders[from] {
+		totalOutput, _ := other.tx.TotalOutput()
+		if spent, err = spent.Add(totalOutput); err != nil {
+			return err
+		}
+	}
+	for otherNonce, other := range mp.queued[from] {
+		if otherNonce == nonce {
+			continue
+		}
+		totalOutput, _ := other.tx.TotalOutput()
+		if spent, err = spent.Add(totalOutput); err != nil {
+			return err
+		}
+	}
+	if spent > mp.tip.BalanceOf(from) {
+		return ErrInsufficientFunds
+	}
+
+	queue, ok := mp.queued[from]
+	if !ok {
+		queue = make(map[uint]*mempoolEntry)
+		mp.queued[from] = queue
+	}
+	if old, ok := queue[nonce]; ok {
+		if err := mp.checkFeeBump(old.tx, entry.tx); err != nil {
+			return err
+		}
+		mp.unqueue(old)
+		return mp.queue(entry)
+	}
+	entry.queued = true
+	queue[nonce] = entry
+	mp.entries[entry.id] = entry
+	mp.size += entry.size
+	return nil
+}
+
+// Replaces the sender's pending transaction that has the same nonce.
+func (mp *Mempool) replace(entry *mempoolEntry) error {
+	pending := mp.senders[entry.tx.From]
+	i := int(entry.tx.Nonce - mp.tip.NextNonce(entry.tx.From))
+	old := pending[i]
+	if err := mp.checkFeeBump(old.tx, entry.tx); err != nil {
+		return err
+	}
+
+	spent, err := entry.tx.TotalOutput()
+	if err != nil {
+		return err
+	}
+	for j, other := range pending {
+		if j == i {
+			continue
+		}
+		totalOutput, _ := other.tx.TotalOutput()
+		if spent, err = spent.Add(totalOutput); err != nil {
+			return err
+		}
+	}
+	if spent > mp.tip.BalanceOf(entry.tx.From) {
+		return ErrInsufficientFunds
+	}
+
+	delete(mp.entries, old.id)
+	mp.entries[entry.id] = entry
+	mp.size += entry.size - old.size
+	pending[i] = entry
+	return nil
+}
+
+func (mp *Mempool) checkFeeBump(old *Transaction, replacement *Transaction) error {
+	percent := Amount(mp.MinFeeBumpPercent)
+	bump := addCapped(mulCapped(old.Fee/100, percent), mulCapped(old.Fee%100, percent)/100)
+	if bump == 0 {
+		bump = 1
+	}
+	minFee, err := old.Fee.Add(bump)
+	if err != nil || replacement.Fee < minFee {
+		return ErrFeeBumpTooLow
+	}
+	return nil
+}
+
+func (mp *Mempool) unqueue(entry *mempoolEntry) {
+	queue := mp.queued[entry.tx.From]
+	delete(queue, entry.tx.Nonce)
+	if len(queue) == 0 {
+		delete(mp.queued, entry.tx.From)
+	}
+	delete(mp.entries, entry.id)
+	mp.size -= entry.size
+}
+
+// Moves the sender's queued transactions into the pool for as long as they
+// follow on from its pending ones. A queued transaction that cannot be
+// promoted stays queued, unless the sender can no longer afford it.
+func (mp *Mempool) promote(from string) {
+	for {
+		entry, ok := mp.queued[from][mp.nextNonce(from)]
+		if !ok {
+			return
+		}
+		mp.unqueue(entry)
+		if err := mp.checkAgainstTip(entry.tx); err != nil {
+			mp.queue(entry)
+			return
+		}
+		mp.insert(entry)
+	}
+}
+
+// The nonce of the sender's next transaction after its pending ones.
+func (mp *Mempool) nextNonce(from string) uint {
+	return mp.tip.NextNonce(from) + uint(len(mp.senders[from]))
+}
+
+// The checks that do not depend on the tip, so that they need not be run
+// again when the tip moves.
+func (mp *Mempool) checkTransaction(tx *Transaction, id string) error {
+	if _, ok := mp.entries[id]; ok {
+		return ErrDuplicateTransaction
+	} else if len(tx.sig) == 0 {
+		return ErrUnsignedTransaction
+	} else if !tx.ValidSignature() {
+		return ErrInvalidSignature
+	} else if tx.Fee < FeeForWeight(mp.MinRelayFeeRate, tx.Weight()) {
+		return ErrFeeRateTooLow
+	}
+	return nil
+}
+
+// Checks tx against the tip and the sender's pending transactions. A nonce
+// ahead of or already used by those is reported with ErrNonceGap or
+// ErrNonceInUse.
+func (mp *Mempool) checkAgainstTip(tx *Transaction) error {
+	if mp.tip == nil {
+		return ErrNoChain
+	}
+	if params := mp.tip.Params(); params != nil {
+		if err := tx.CheckAddresses(params.Network); err != nil {
+			return err
+		}
+	}
+	if tx.Weight() > mp.tip.maxWeight() {
+		return ErrBlockFull
+	}
+
+	pending := mp.senders[tx.From]
+	nonce := mp.tip.NextNonce(tx.From)
+	if tx.Nonce < nonce {
+		return ErrNonceTooLow
+	} else if tx.Nonce < nonce+uint(len(pending)) {
+		return ErrNonceInUse
+	} else if tx.Nonce > nonce+uint(len(pending)) {
+		return ErrNonceGap
+	}
+
+	spent, err := tx.TotalOutput()
+	if err != nil {
+		return err
+	}
+	for _, entry := range pending {
+		totalOutput, _ := entry.tx.TotalOutput()
+		if spent, err = spent.Add(totalOutput); err != nil {
+			return err
+		}
+	}
+	if spent > mp.tip.BalanceOf(tx.From) {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// Removes the transaction with the lowest fee rate that no other pending
+// transaction depends on, that is a queued one or the last one of some
+// sender.
+func (mp *Mempool) evict() *mempoolEntry {
+	var victim *mempoolEntry
+	consider := func(entry *mempoolEntry) {
+		if victim == nil || victim.paysMoreThan(entry) {
+			victim = entry
+		}
+	}
+	for _, pending := range mp.senders {
+		consider(pending[len(pending)-1])
+	}
+	for _, queue := range mp.queued {
+		for _, entry := range queue {
+			consider(entry)
+		}
+	}
+	if victim.queued {
+		mp.unqueue(victim)
+	} else {
+		mp.removeFrom(victim.tx.From, len(mp.senders[victim.tx.From])-1)
+	}
+	return victim
+}
+
+// Removes the sender's pending transactions from index i onward.
+func (mp *Mempool) removeFrom(from string, i int) {
+	pending := mp.senders[from]
+	for _, entry := range pending[i:] {
+		delete(mp.entries, entry.id)
+		mp.size -= entry.size
+	}
+	if i == 0 {
+		delete(mp.senders, from)
+	} else {
+		mp.senders[from] = pending[:i]
+	}
+}
+
+// Drops transactions that have been pending for longer than Expiry, along
+// with the later transactions of the same sender that depend on them, and
+// queued transactions that have waited for longer than Expiry.
+func (mp *Mempool) Expire() {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.expire()
+}
+
+func (mp *Mempool) expire() {
+	cutoff := mp.Clock.Now().Add(-mp.Expiry)
+	for from, pending := range mp.senders {
+		for i, entry := range pending {
+			if entry.added.Before(cutoff) {
+				mp.removeFrom(from, i)
+				break
+			}
+		}
+	}
+	for _, queue := range mp.queued {
+		for _, entry := range queue {
+			if entry.added.Before(cutoff) {
+				mp.unqueue(entry)
+			}
+		}
+	}
+}
+
+// Moves the pool onto a new tip. Transactions from blocks that are no longer
+// on the chain are offered to the pool again, and every pending and queued
+// transaction is checked against the new tip again, so those confirmed by the
+// new chain or no longer valid on it are dropped, and queued ones whose gap
+// the new chain fills are promoted. Signatures and fee rates of transactions
+// already in the pool are not checked again.
+func (mp *Mempool) SetTip(tip *Block, disconnected ...*Transaction) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	now := mp.Clock.Now()
+	entries := make([]*mempoolEntry, 0, len(mp.entries)+len(disconnected))
+	for _, entry := range mp.entries {
+		entries = append(entries, entry)
+	}
+	for _, tx := range disconnected {
+		id := tx.Id()
+		if err := mp.checkTransaction(tx, id); err != nil {
+			continue
+		}
+		entry := &mempoolEntry{tx: tx, id: id, size: int(tx.Weight()), added: now}
+		mp.entries[id] = entry
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].tx.From != entries[j].tx.From {
+			return entries[i].tx.From < entries[j].tx.From
+		}
+		return entries[i].tx.Nonce < entries[j].tx.Nonce
+	})
+
+	mp.tip = tip
+	mp.entries = make(map[string]*mempoolEntry)
+	mp.senders = make(map[string][]*mempoolEntry)
+	mp.queued = make(map[string]map[uint]*mempoolEntry)
+	mp.size = 0
+	for _, entry := range entries {
+		mp.addChecked(entry)
+	}
+	mp.expire()
+}
+
+// The pending transactions in the order a miner should include them: highest
+// fee rate first, except that each sender's transactions stay in nonce order. Ties
+// are broken by id to keep the order deterministic.
+func (mp *Mempool) Select() []*Transaction {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	txs := make([]*Transaction, 0, len(mp.entries))
+	queue := make(feeQueue, 0, len(mp.senders))
+	for _, pending := range mp.senders {
+		queue = append(queue, pending)
+	}
+	heap.Init(&queue)
+	for queue.Len() > 0 {
+		pending := queue[0]
+		txs = append(txs, pending[0].tx)
+		if len(pending) > 1 {
+			queue[0] = pending[1:]
+			heap.Fix(&queue, 0)
+		} else {
+			heap.Pop(&queue)
+		}
+	}
+	return txs
+}
+
+// Whether e pays a higher fee rate than other, with ties broken by id.
+func (e *mempoolEntry) paysMoreThan(other *mempoolEntry) bool {
+	// compare e.tx.Fee/e.size with other.tx.Fee/other.size without dividing
+	hi1, lo1 := bits.Mul64(uint64(e.tx.Fee), uint64(other.size))
+	hi2, lo2 := bits.Mul64(uint64(other.tx.Fee), uint64(e.size))
+	if hi1 != hi2 {
+		return hi1 > hi2
+	} else if lo1 != lo2 {
+		return lo1 > lo2
+	}
+	return e.id < other.id
+}
+
+// A max-heap of each sender's remaining transactions, ordered by the fee rate
+// of the first one.
+type feeQueue [][]*mempoolEntry
+
+func (q feeQueue) Len() int {
+	return len(q)
+}
+
+func (q feeQueue) Less(i, j int) bool {
+	return q[i][0].paysMoreThan(q[j][0])
+}
+
+func (q feeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *feeQueue) Push(x interface{}) {
+	*q = append(*q, x.([]*mempoolEntry))
+}
+
+func (q *feeQueue) Pop() interface{} {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,183 @@
+package spartan_go
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// A mempool on the tip of a regtest genesis block that funds each sender,
+// with a test clock so that expiry can be checked.
+func newMempoolTest(t *testing.T, cfg *Mempool, senders int) (*Mempool, []*Client, *Block, *testClock) {
+	params := RegTestParams()
+	clients := make([]*Client, senders)
+	balances := make(map[*Client]Amount, senders)
+	for i := range clients {
+		clients[i] = NewClient(&Client{Name: "Sender", Net: NewFakeNet(&FakeNet{}), Params: params})
+		balances[clients[i]] = 1000 * params.DefaultTxFee
+	}
+	genesis, err := MakeGenesis(&Blockchain{
+		Params:           params,
+		ClientBalanceMap: balances,
+		Timestamp:        testClockStart,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newTestClock(testClockStart)
+	cfg.Clock = clock
+	cfg.MinRelayFeeRate = params.MinRelayFeeRate
+	mp := NewMempool(cfg)
+	mp.SetTip(genesis)
+	return mp, clients, genesis, clock
+}
+
+// The lowest fee the mempool relays for a transaction from sender.
+func mempoolTestRelayFee(mp *Mempool, sender *Client) Amount {
+	return FeeForWeight(mp.MinRelayFeeRate, feeTestTx(sender, 0, 0).Weight())
+}
+
+func addMempoolTestTxs(t *testing.T, mp *Mempool, txs ...*Transaction) {
+	for _, tx := range txs {
+		if err := mp.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func selectedIds(mp *Mempool) []string {
+	ids := make([]string, 0)
+	for _, tx := range mp.Select() {
+		ids = append(ids, tx.Id())
+	}
+	return ids
+}
+
+func TestMempoolQueuesThenPromotes(t *testing.T) {
+	mp, senders, _, _ := newMempoolTest(t, &Mempool{}, 1)
+	sender := senders[0]
+	fee := mempoolTestRelayFee(mp, sender)
+	tx0 := feeTestTx(sender, 0, fee)
+	tx1 := feeTestTx(sender, 1, fee)
+
+	addMempoolTestTxs(t, mp, tx1)
+	if mp.QueuedLen() != 1 || len(mp.Select()) != 0 {
+		t.Fatalf("transaction ahead of the sender's nonce should be queued, not selected")
+	}
+	addMempoolTestTxs(t, mp, tx0)
+	if mp.QueuedLen() != 0 || mp.Len() != 2 {
+		t.Fatalf("queued transaction was not promoted: %d queued of %d", mp.QueuedLen(), mp.Len())
+	}
+	if ids := selectedIds(mp); len(ids) != 2 || ids[0] != tx0.Id() || ids[1] != tx1.Id() {
+		t.Error("promoted transactions are not selected in nonce order")
+	}
+}
+
+func TestMempoolFeeBump(t *testing.T) {
+	mp, senders, _, _ := newMempoolTest(t, &Mempool{}, 1)
+	sender := senders[0]
+	fee := 100 * mempoolTestRelayFee(mp, sender)
+	bumped := fee + fee*MEMPOOL_MIN_FEE_BUMP_PERCENT/100
+
+	// nonce 0 is pending and nonce 2 is queued behind the missing nonce 1
+	for _, nonce := range []uint{0, 2} {
+		old := feeTestTx(sender, nonce, fee)
+		addMempoolTestTxs(t, mp, old)
+
+		if err := mp.Add(feeTestTx(sender, nonce, bumped-1)); !errors.Is(err, ErrFeeBumpTooLow) {
+			t.Errorf("nonce %d: got %v, want %v", nonce, err, ErrFeeBumpTooLow)
+		}
+		if !mp.Has(old.Id()) {
+			t.Errorf("nonce %d: original was dropped by a rejected replacement", nonce)
+		}
+
+		replacement := feeTestTx(sender, nonce, bumped)
+		addMempoolTestTxs(t, mp, replacement)
+		if mp.Has(old.Id()) || !mp.Has(replacement.Id()) {
+			t.Errorf("nonce %d: replacement did not take the original's place", nonce)
+		}
+	}
+	if mp.Len() != 2 || mp.QueuedLen() != 1 {
+		t.Errorf("%d transactions with %d queued, want 2 with 1 queued", mp.Len(), mp.QueuedLen())
+	}
+}
+
+func TestMempoolEvictsLowestFeeRate(t *testing.T) {
+	mp, senders, _, _ := newMempoolTest(t, &Mempool{}, 4)
+	fee := mempoolTestRelayFee(mp, senders[0])
+	txs := []*Transaction{
+		feeTestTx(senders[0], 0, 3*fee),
+		feeTestTx(senders[1], 0, fee),
+		feeTestTx(senders[2], 0, 2*fee),
+	}
+	mp.MaxSize = int(txs[0].Weight() + txs[2].Weight())
+
+	addMempoolTestTxs(t, mp, txs...)
+	if mp.Len() != 2 || mp.Has(txs[1].Id()) {
+		t.Fatal("the transaction paying the lowest fee rate was not evicted")
+	}
+	if err := mp.Add(feeTestTx(senders[3], 0, fee)); !errors.Is(err, ErrMempoolFull) {
+		t.Errorf("got %v, want %v for a transaction paying less than the pool", err, ErrMempoolFull)
+	}
+	if !mp.Has(txs[0].Id()) || !mp.Has(txs[2].Id()) {
+		t.Error("a transaction paying more than the rejected one was evicted")
+	}
+}
+
+func TestMempoolSetTipDropsAndReadds(t *testing.T) {
+	mp, senders, genesis, _ := newMempoolTest(t, &Mempool{}, 1)
+	sender := senders[0]
+	fee := mempoolTestRelayFee(mp, sender)
+	tx0 := feeTestTx(sender, 0, fee)
+	tx1 := feeTestTx(sender, 1, fee)
+	addMempoolTestTxs(t, mp, tx0, tx1)
+
+	b := NewBlock(nil, sender.Address, genesis, genesis.Target)
+	if err := b.addTransaction(tx0); err != nil {
+		t.Fatal(err)
+	}
+	mp.SetTip(b)
+	if mp.Has(tx0.Id()) || !mp.Has(tx1.Id()) {
+		t.Fatal("connecting a block should drop only the transactions it confirms")
+	}
+
+	mp.SetTip(genesis, b.Transactions...)
+	if ids := selectedIds(mp); len(ids) != 2 || ids[0] != tx0.Id() || ids[1] != tx1.Id() {
+		t.Error("disconnecting a block should return its transactions to the pool")
+	}
+}
+
+func TestMempoolExpiry(t *testing.T) {
+	mp, senders, _, clock := newMempoolTest(t, &Mempool{Expiry: time.Hour}, 2)
+	fee := mempoolTestRelayFee(mp, senders[0])
+	old := feeTestTx(senders[0], 0, fee)
+	promoted := feeTestTx(senders[1], 1, fee)
+	addMempoolTestTxs(t, mp, old, promoted)
+
+	// dependent needs old, which expires first, and promoted waited in the
+	// queue until fresh arrived, but has been in the pool since it was queued
+	clock.Set(testClockStart.Add(30 * time.Minute))
+	dependent := feeTestTx(senders[0], 1, fee)
+	fresh := feeTestTx(senders[1], 0, fee)
+	addMempoolTestTxs(t, mp, dependent, fresh)
+	if mp.Len() != 4 {
+		t.Fatalf("%d transactions before expiry, want 4", mp.Len())
+	}
+
+	clock.Set(testClockStart.Add(time.Hour))
+	mp.Expire()
+	if mp.Len() != 4 {
+		t.Fatalf("transactions expired at exactly Expiry")
+	}
+
+	clock.Set(testClockStart.Add(time.Hour + time.Second))
+	mp.Expire()
+	for name, tx := range map[string]*Transaction{"old": old, "dependent": dependent, "promoted": promoted} {
+		if mp.Has(tx.Id()) {
+			t.Errorf("%s transaction did not expire", name)
+		}
+	}
+	if !mp.Has(fresh.Id()) {
+		t.Error("a transaction added later expired too")
+	}
+}
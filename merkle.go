@@ -0,0 +1,112 @@
+package spartan_go
+
+const (
+	MERKLE_LEAF_CONST = "MERKLE_LEAF"
+	MERKLE_NODE_CONST = "MERKLE_NODE"
+)
+
+// Computes the Merkle root over an ordered list of transaction ids. Leaves
+// and internal nodes are hashed with different prefixes, so an internal node
+// can never pass for a transaction id. When a level has an odd number of
+// nodes, the last node is promoted to the next level as is rather than being
+// paired with itself.
+func MerkleRoot(txIds []string) string {
+	if len(txIds) == 0 {
+		return Hash(MERKLE_NODE_CONST, "")
+	}
+
+	level := merkleLeaves(txIds)
+	for len(level) > 1 {
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, hashMerkleNode(level[i], level[i+1]))
+			}
+		}
+		level = next
+	}
+	return level[0]
+}
+
+func merkleLeaves(txIds []string) []string {
+	leaves := make([]string, len(txIds))
+	for i, txId := range txIds {
+		leaves[i] = hashMerkleLeaf(txId)
+	}
+	return leaves
+}
+
+func hashMerkleLeaf(txId string) string {
+	return Hash(MERKLE_LEAF_CONST+txId, "")
+}
+
+func hashMerkleNode(left string, right string) string {
+	return Hash(MERKLE_NODE_CONST+left+right, "")
+}
+
+// One step of a Merkle branch: the sibling hash to combine with, and whether
+// that sibling sits to the left of the running hash.
+type MerkleStep struct {
+	Hash string
+	Left bool
+}
+
+// Proves that the transaction TxId is committed to by Header.
+type MerkleProof struct {
+	TxId   string
+	Header *BlockHeader
+	Branch []MerkleStep
+}
+
+// Returns the Merkle branch linking txIds[index] to MerkleRoot(txIds).
+// Levels where the node is promoted without a sibling contribute no step.
+func MerkleBranch(txIds []string, index int) []MerkleStep {
+	branch := make([]MerkleStep, 0)
+	level := merkleLeaves(txIds)
+	for len(level) > 1 {
+		if index%2 == 1 {
+			branch = append(branch, MerkleStep{Hash: level[index-1], Left: true})
+		} else if index+1 < len(level) {
+			branch = append(branch, MerkleStep{Hash: level[index+1], Left: false})
+		}
+
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, hashMerkleNode(level[i], level[i+1]))
+			}
+		}
+		level = next
+		index /= 2
+	}
+	return branch
+}
+
+// Folds a Merkle branch starting from the leaf for txId and returns the root.
+func MerkleRootFromBranch(txId string, branch []MerkleStep) string {
+	h := hashMerkleLeaf(txId)
+	for _, step := range branch {
+		if step.Left {
+			h = hashMerkleNode(step.Hash, h)
+		} else {
+			h = hashMerkleNode(h, step.Hash)
+		}
+	}
+	return h
+}
+
+// Checks that proof shows its transaction to be included in the block whose
+// hash is blockHash.
+func VerifyMerkleProof(blockHash string, proof *MerkleProof) bool {
+	if proof == nil || proof.Header == nil {
+		return false
+	}
+	if proof.Header.HashVal() != blockHash {
+		return false
+	}
+	return MerkleRootFromBranch(proof.TxId, proof.Branch) == proof.Header.MerkleRoot
+}
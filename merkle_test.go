@@ -0,0 +1,119 @@
+package spartan_go
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func merkleTestIds(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = Hash("tx"+strconv.Itoa(i), "")
+	}
+	return ids
+}
+
+var merkleVectors = []struct {
+	leaves int
+	root   string
+	first  []MerkleStep
+	last   []MerkleStep
+}{
+	{
+		leaves: 1,
+		root:   "f355f8326b81f266a8f5ead49c1885514135921862fd7560712031db675be91b",
+		first:  []MerkleStep{},
+		last:   []MerkleStep{},
+	},
+	{
+		leaves: 2,
+		root:   "345d388e9310c80731c94954a447696cc940d79ae0e4c3b9250fe144c7137e2f",
+		first: []MerkleStep{
+			{Hash: "c17dfad23c67cccd38fca0a0b2a34b025c21ebbaf4041654bb0fedc02e6abb4d", Left: false},
+		},
+		last: []MerkleStep{
+			{Hash: "f355f8326b81f266a8f5ead49c1885514135921862fd7560712031db675be91b", Left: true},
+		},
+	},
+	{
+		leaves: 3,
+		root:   "68ddda37c9b3635d4ebcfd5261874cb88cfdbea7779935359e0d720f9750759d",
+		first: []MerkleStep{
+			{Hash: "c17dfad23c67cccd38fca0a0b2a34b025c21ebbaf4041654bb0fedc02e6abb4d", Left: false},
+			{Hash: "0c9c5cfb09489765fd4d6456ce90214b6b67a9d2364191093181d2a9329d587e", Left: false},
+		},
+		last: []MerkleStep{
+			{Hash: "345d388e9310c80731c94954a447696cc940d79ae0e4c3b9250fe144c7137e2f", Left: true},
+		},
+	},
+	{
+		leaves: 5,
+		root:   "2c3bf115490261c39696f7372902a32fec93486fda8047503719d7e989de54a0",
+		first: []MerkleStep{
+			{Hash: "c17dfad23c67cccd38fca0a0b2a34b025c21ebbaf4041654bb0fedc02e6abb4d", Left: false},
+			{Hash: "f7c7763dc240b3d61419f9217d79b2ca96a8acc9536fea06a2529b2c95ad5ad8", Left: false},
+			{Hash: "7aa357b2b1d3f7b880b26cc099bbf9e244a7a944c914710758a1bd5387e18757", Left: false},
+		},
+		last: []MerkleStep{
+			{Hash: "685a64e476c8af1027412f10ac90ba84720347ae3850270324e296c94dab8725", Left: true},
+		},
+	},
+	{
+		leaves: 7,
+		root:   "b350a08f1fc4f8fede81c93d83aefc1aec8a7b4d048253455c912fed4c2eda8c",
+		first: []MerkleStep{
+			{Hash: "c17dfad23c67cccd38fca0a0b2a34b025c21ebbaf4041654bb0fedc02e6abb4d", Left: false},
+			{Hash: "f7c7763dc240b3d61419f9217d79b2ca96a8acc9536fea06a2529b2c95ad5ad8", Left: false},
+			{Hash: "4138ebbacdc1b466765e2a794604016fc3d52d7226fdcef424b1a71f642c0c8b", Left: false},
+		},
+		last: []MerkleStep{
+			{Hash: "a395875f11984c5435b6ac155afd7fd871e5cefd611d85730db493f02543c392", Left: true},
+			{Hash: "685a64e476c8af1027412f10ac90ba84720347ae3850270324e296c94dab8725", Left: true},
+		},
+	},
+}
+
+func TestMerkleRootVectors(t *testing.T) {
+	for _, v := range merkleVectors {
+		if root := MerkleRoot(merkleTestIds(v.leaves)); root != v.root {
+			t.Errorf("%d leaves: root %s, want %s", v.leaves, root, v.root)
+		}
+	}
+}
+
+func TestMerkleBranchVectors(t *testing.T) {
+	for _, v := range merkleVectors {
+		ids := merkleTestIds(v.leaves)
+		if branch := MerkleBranch(ids, 0); !reflect.DeepEqual(branch, v.first) {
+			t.Errorf("%d leaves: branch of first leaf %v, want %v", v.leaves, branch, v.first)
+		}
+		if branch := MerkleBranch(ids, v.leaves-1); !reflect.DeepEqual(branch, v.last) {
+			t.Errorf("%d leaves: branch of last leaf %v, want %v", v.leaves, branch, v.last)
+		}
+	}
+}
+
+func TestMerkleBranchFoldsToRoot(t *testing.T) {
+	for _, v := range merkleVectors {
+		ids := merkleTestIds(v.leaves)
+		for i, id := range ids {
+			if root := MerkleRootFromBranch(id, MerkleBranch(ids, i)); root != v.root {
+				t.Errorf("%d leaves: branch of leaf %d folds to %s, want %s", v.leaves, i, root, v.root)
+			}
+		}
+	}
+}
+
+func TestMerkleRootFromBranchRejectsInternalNode(t *testing.T) {
+	ids := merkleTestIds(4)
+	root := MerkleRoot(ids)
+	left := hashMerkleNode(hashMerkleLeaf(ids[0]), hashMerkleLeaf(ids[1]))
+	right := hashMerkleNode(hashMerkleLeaf(ids[2]), hashMerkleLeaf(ids[3]))
+	if hashMerkleNode(left, right) != root {
+		t.Fatal("test tree does not match MerkleRoot")
+	}
+	if MerkleRootFromBranch(left, []MerkleStep{{Hash: right, Left: false}}) == root {
+		t.Error("internal node verified as a transaction id")
+	}
+}
@@ -2,6 +2,7 @@ package spartan_go
 
 import (
 	"strconv"
+	"sync"
 
 	. "github.com/vansante/go-event-emitter"
 )
@@ -11,21 +12,20 @@ type Miner struct {
 	Client       *Client
 	CurrentBlock *Block
 	miningRounds uint
-	transactions map[string]*Transaction
+	rewardShares []RewardShare
+	sharesLock   sync.Mutex
 }
 
 func NewMiner(cfg *Client, miningRounds ...uint) *Miner {
-	var rounds uint
+	client := NewClient(cfg)
+	rounds := client.Params.NumRoundsMining
 	if len(miningRounds) == 1 {
 		rounds = miningRounds[0]
-	} else {
-		rounds = NUM_ROUNDS_MINING
 	}
 	miner := &Miner{
 		Emitter:      *NewEmitter(true),
-		Client:       NewClient(cfg),
+		Client:       client,
 		miningRounds: rounds,
-		transactions: make(map[string]*Transaction),
 	}
 	miner.AddListener(START_MINING, miner.findProof)
 	miner.AddListener(POST_TRANSACTION, miner.addTransaction)
@@ -34,6 +34,35 @@ func NewMiner(cfg *Client, miningRounds ...uint) *Miner {
 	return miner
 }
 
+// A miner whose block rewards are split between several payout addresses
+// instead of going to its own address.
+func NewPoolMiner(cfg *Client, shares []RewardShare, miningRounds ...uint) (*Miner, error) {
+	miner := NewMiner(cfg, miningRounds...)
+	if err := ValidateRewardShares(shares, miner.Client.Params.Network); err != nil {
+		return nil, err
+	}
+	miner.rewardShares = append([]RewardShare(nil), shares...)
+	return miner, nil
+}
+
+// Changes how rewards are paid out, starting with the next block the miner
+// searches for. With no shares, rewards go to the miner's own address again.
+func (m *Miner) SetRewardShares(shares []RewardShare) error {
+	if err := ValidateRewardShares(shares, m.Client.Params.Network); err != nil {
+		return err
+	}
+	m.sharesLock.Lock()
+	defer m.sharesLock.Unlock()
+	m.rewardShares = append([]RewardShare(nil), shares...)
+	return nil
+}
+
+func (m *Miner) RewardShares() []RewardShare {
+	m.sharesLock.Lock()
+	defer m.sharesLock.Unlock()
+	return append([]RewardShare(nil), m.rewardShares...)
+}
+
 func (m *Miner) Initialize() {
 	m.startNewSearch()
 	// go func() {
@@ -41,23 +70,29 @@ func (m *Miner) Initialize() {
 	// }()
 }
 
-func (m *Miner) startNewSearch(transactions ...map[string]*Transaction) {
-	var txMap map[string]*Transaction
-	if len(transactions) != 1 {
-		txMap = make(map[string]*Transaction)
-	} else {
-		txMap = transactions[0]
+func (m *Miner) startNewSearch() {
+	target := m.Client.Params.DifficultyAdjuster.NextTarget(m.Client.LastBlock)
+	m.CurrentBlock = NewBlock(m.Client.Params, m.Client.Address, m.Client.LastBlock, target)
+	if shares := m.RewardShares(); len(shares) != 0 {
+		m.CurrentBlock.RewardAddr = ""
+		m.CurrentBlock.RewardShares = shares
 	}
-
-	m.CurrentBlock = NewBlock(m.Client.Address, m.Client.LastBlock, nil)
-	for id, tx := range txMap {
-		m.transactions[id] = tx
-	}
-
-	for _, tx := range m.transactions {
-		m.CurrentBlock.AddTransaction(tx, m.Client)
+	m.CurrentBlock.Timestamp = m.Client.nextTimestamp(m.Client.LastBlock)
+	// once a sender's transaction is left out, its later ones cannot be
+	// included either
+	skipped := make(map[string]bool)
+	for _, tx := range m.Client.Mempool.Select() {
+		if skipped[tx.From] {
+			continue
+		}
+		if m.CurrentBlock.Weight()+tx.Weight() > m.CurrentBlock.maxWeight() {
+			skipped[tx.From] = true
+			continue
+		}
+		if err := m.CurrentBlock.AddTransaction(tx, m.Client); err != nil {
+			skipped[tx.From] = true
+		}
 	}
-	m.transactions = make(map[string]*Transaction)
 	m.CurrentBlock.Proof = 0
 }
 
@@ -70,7 +105,7 @@ func (m *Miner) findProof(oneAndDone ...interface{}) {
 	pausePoint := m.CurrentBlock.Proof + m.miningRounds
 	for m.CurrentBlock.Proof < pausePoint {
 		if m.CurrentBlock.HasValidProof() {
-			m.Client.log("Found proof for block " + strconv.FormatUint(uint64(m.CurrentBlock.ChainLength), 10) + ": " + strconv.FormatUint(uint64(m.CurrentBlock.Proof), 10))
+			m.Client.log("Found proof for block " + strconv.FormatUint(uint64(m.CurrentBlock.ChainLength), 10) + ": " + strconv.FormatUint(uint64(m.CurrentBlock.Proof), 10) + ", earning " + m.Client.Params.FormatAmount(m.CurrentBlock.TotalRewards()))
 			m.announceProof()
 			m.receiveBlock(m.CurrentBlock)
 			break
@@ -93,70 +128,36 @@ func (m *Miner) announceProof() {
 func (m *Miner) receiveBlock(block ...interface{}) {
 	b := block[0].(*Block)
 
-	b = m.Client.receiveBlockHelper(b)
-	if b == nil {
+	tip := m.Client.LastBlock
+	b, err := m.Client.receiveBlockHelper(b)
+	if err != nil || b == nil {
 		return
 	}
 
-	if m.CurrentBlock != nil && b.ChainLength >= m.CurrentBlock.ChainLength {
+	// the client's fork choice decides whether the miner switches chains, and
+	// the mempool has already been moved onto the new chain
+	if m.CurrentBlock != nil && m.Client.LastBlock != tip {
 		m.Client.log("Cutting over to new chain")
-		txMap := m.syncTransactions(b)
-		m.startNewSearch(txMap)
-	}
-}
-
-func (m *Miner) syncTransactions(nb *Block) map[string]*Transaction {
-	m.Client.blocksLock.Lock()
-	defer m.Client.blocksLock.Unlock()
-
-	cb := m.CurrentBlock
-	cbTxs := make(map[string]*Transaction)
-	nbTxs := make(map[string]*Transaction)
-
-	for nb.ChainLength > cb.ChainLength {
-		for id, tx := range nb.Transactions {
-			nbTxs[id] = tx
-		}
-		nb = m.Client.blocks[nb.PrevBlockHash]
-	}
-
-	for cb != nil && cb.HashVal() != nb.HashVal() {
-		for id, tx := range cb.Transactions {
-			cbTxs[id] = tx
-		}
-		for id, tx := range nb.Transactions {
-			nbTxs[id] = tx
-		}
-		cb = m.Client.blocks[cb.PrevBlockHash]
-		nb = m.Client.blocks[nb.PrevBlockHash]
-	}
-
-	for id := range nbTxs {
-		delete(cbTxs, id)
+		m.startNewSearch()
 	}
-
-	return cbTxs
 }
 
 func (m *Miner) addTransaction(txs ...interface{}) {
-	if len(txs) != 1 {
-		m.Client.log("addTransaction(...) requires 1 transaction parameter")
-		return
-	}
-	tx := txs[0].(*Transaction)
-	newTx := NewTransaction(tx.From, tx.Nonce, tx.PubKey, tx.sig, tx.Fee, tx.Outputs)
-	m.transactions[newTx.Id()] = newTx
+	m.Client.receiveTransaction(txs...)
 }
 
 func (m *Miner) provideMissingBlock(o ...interface{}) {
 	m.Client.provideMissingBlock(o...)
 }
 
-func (m *Miner) PostTransaction(outputs []TxOuput, fee ...uint) {
-	tx, err := m.Client.PostTransaction(outputs, fee...)
-	if err != nil {
+func (m *Miner) PostTransaction(outputs []TxOuput, fee ...Amount) {
+	if _, err := m.Client.PostTransaction(outputs, fee...); err != nil {
+		m.Client.log(err.Error())
+	}
+}
+
+func (m *Miner) PostPayment(addr string, amount string) {
+	if _, err := m.Client.PostPayment(addr, amount); err != nil {
 		m.Client.log(err.Error())
-		return
 	}
-	m.addTransaction(tx)
 }
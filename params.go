@@ -0,0 +1,168 @@
+package spartan_go
+
+import (
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+// The consensus rules and defaults of one chain. Every client, miner and
+// block holds a pointer to the parameters of the chain it belongs to, so
+// several chains can run side by side in one process. Parameters must not be
+// modified once a chain is in use.
+//
+// All amounts are in base units, and one gold is AmountDecimals decimal
+// places of base units. CoinbaseReward is the initial block subsidy, which halves every
+// HalvingInterval blocks (never, if zero). MaxSupply caps the total subsidy
+// ever paid out (no cap, if zero). Block rewards can only be spent once the
+// block has CoinbaseMaturity confirmations; a maturity of 1 lets them be spent
+// in the next block.
+//
+// The transactions in a block may weigh at most MaxBlockWeight in total (no
+// limit, if zero).
+// Clients only accept transactions into their mempool that pay at least
+// MinRelayFeeRate per FEE_RATE_WEIGHT weight units.
+type ChainParams struct {
+	Name               string
+	Network            Network
+	PowTarget          *uint256.Int
+	AmountDecimals     uint
+	CoinbaseReward     Amount
+	HalvingInterval    uint
+	MaxSupply          Amount
+	CoinbaseMaturity   uint
+	DefaultTxFee       Amount
+	MaxBlockWeight     uint
+	MinRelayFeeRate    Amount
+	ConfirmedDepth     uint
+	NumRoundsMining    uint
+	MedianTimeSpan     uint
+	MaxFutureDrift     time.Duration
+	DifficultyAdjuster DifficultyAdjuster
+}
+
+// Returns the target with the given number of leading zero bits, without
+// modifying POW_TARGET.
+func TargetWithLeadingZeroes(zeroes uint) *uint256.Int {
+	return new(uint256.Int).Rsh(POW_TARGET, zeroes)
+}
+
+// Returns the preset parameters for a network.
+func ParamsForNetwork(network Network) *ChainParams {
+	switch network {
+	case TESTNET:
+		return TestNetParams()
+	case REGTEST:
+		return RegTestParams()
+	default:
+		return MainNetParams()
+	}
+}
+
+func MainNetParams() *ChainParams {
+	return &ChainParams{
+		Name:               "mainnet",
+		Network:            MAINNET,
+		PowTarget:          TargetWithLeadingZeroes(POW_LEADING_ZEROES),
+		AmountDecimals:     AMOUNT_DECIMALS,
+		CoinbaseReward:     COINBASE_AMT_ALLOWED * UnitsPerGold(AMOUNT_DECIMALS),
+		HalvingInterval:    COINBASE_HALVING_INTERVAL,
+		CoinbaseMaturity:   COINBASE_MATURITY,
+		DefaultTxFee:       DEFAULT_TX_FEE * UnitsPerGold(AMOUNT_DECIMALS),
+		ConfirmedDepth:     CONFIRMED_DEPTH,
+		MaxBlockWeight:     MAX_BLOCK_WEIGHT,
+		MinRelayFeeRate:    MIN_RELAY_FEE_RATE,
+		NumRoundsMining:    NUM_ROUNDS_MINING,
+		MedianTimeSpan:     MEDIAN_TIME_SPAN,
+		MaxFutureDrift:     MAX_FUTURE_DRIFT,
+		DifficultyAdjuster: FixedDifficulty{},
+	}
+}
+
+// Like mainnet, but with easier blocks whose target follows the recent block
+// rate. The target never gets easier than PowTarget, and since blocks come
+// every second, a block may only be a few spacings ahead of the clock, or a
+// miner could make the next target easier by stamping blocks hours ahead.
+func TestNetParams() *ChainParams {
+	spacing := time.Second
+	params := MainNetParams()
+	params.Name = "testnet"
+	params.Network = TESTNET
+	params.PowTarget = TargetWithLeadingZeroes(16)
+	params.MaxFutureDrift = 2 * spacing
+	params.DifficultyAdjuster = &MovingAverageRetarget{
+		Window:        11,
+		TargetSpacing: spacing,
+		MaxAdjustment: 4,
+	}
+	return params
+}
+
+// For local testing: proofs are found almost immediately and blocks confirm
+// after a single block.
+func RegTestParams() *ChainParams {
+	params := MainNetParams()
+	params.Name = "regtest"
+	params.Network = REGTEST
+	params.PowTarget = TargetWithLeadingZeroes(1)
+	params.ConfirmedDepth = 1
+	params.CoinbaseMaturity = 1
+	return params
+}
+
+// The total subsidy paid out by the blocks at heights 1 through height. The
+// genesis block pays no subsidy, and its starting balances are not counted.
+func (p *ChainParams) TotalSupplyAt(height uint) Amount {
+	total := Amount(0)
+	subsidy := p.CoinbaseReward
+	for start := uint(1); start <= height && subsidy > 0; {
+		blocks := height - start + 1
+		if p.HalvingInterval != 0 && blocks > p.HalvingInterval {
+			blocks = p.HalvingInterval
+		}
+		total = addCapped(total, mulCapped(Amount(blocks), subsidy))
+		if p.HalvingInterval == 0 {
+			break
+		}
+		start += blocks
+		subsidy >>= 1
+	}
+	if p.MaxSupply != 0 && total > p.MaxSupply {
+		total = p.MaxSupply
+	}
+	return total
+}
+
+// The subsidy that the block at height must claim as its CoinbaseReward.
+func (p *ChainParams) SubsidyAt(height uint) Amount {
+	if height == 0 {
+		return 0
+	}
+	return p.TotalSupplyAt(height) - p.TotalSupplyAt(height-1)
+}
+
+// Saturating arithmetic, so that a schedule over a very long chain stops at
+// the largest value rather than wrapping around.
+func addCapped(a Amount, b Amount) Amount {
+	sum, err := a.Add(b)
+	if err != nil {
+		return MAX_AMOUNT
+	}
+	return sum
+}
+
+func mulCapped(a Amount, b Amount) Amount {
+	if a != 0 && (a*b)/a != b {
+		return MAX_AMOUNT
+	}
+	return a * b
+}
+
+// Parses an amount of gold such as "1.25 gold" into this chain's base units.
+func (p *ChainParams) ParseAmount(s string) (Amount, error) {
+	return ParseAmount(s, p.AmountDecimals)
+}
+
+func (p *ChainParams) FormatAmount(a Amount) string {
+	return FormatAmount(a, p.AmountDecimals)
+}
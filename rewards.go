@@ -0,0 +1,86 @@
+package spartan_go
+
+import (
+	"errors"
+	"math/bits"
+	"strconv"
+)
+
+const MAX_REWARD_SHARES = 16
+
+// One payout address of a block reward and its weight. The block's total
+// rewards are split between its shares in proportion to their weights.
+type RewardShare struct {
+	Address string `json:"address"`
+	Weight  uint   `json:"weight"`
+}
+
+// Shares must have distinct, valid addresses and positive weights, and there
+// may be at most MAX_REWARD_SHARES of them. If a network is given, every
+// address must be for that network.
+func ValidateRewardShares(shares []RewardShare, network ...Network) error {
+	if len(shares) > MAX_REWARD_SHARES {
+		return errors.New("At most " + strconv.Itoa(MAX_REWARD_SHARES) + " reward shares are allowed")
+	}
+	seen := make(map[string]bool)
+	totalWeight := uint(0)
+	for _, share := range shares {
+		if len(share.Address) == 0 {
+			return errors.New("Reward share has no address")
+		}
+		if _, _, err := ParseAddress(share.Address, network...); err != nil {
+			return err
+		}
+		if share.Weight == 0 {
+			return errors.New("Reward share for " + share.Address + " has no weight")
+		}
+		if seen[share.Address] {
+			return errors.New("Duplicate reward share for " + share.Address)
+		}
+		seen[share.Address] = true
+		if totalWeight+share.Weight < totalWeight {
+			return errors.New("Reward share weights overflow")
+		}
+		totalWeight += share.Weight
+	}
+	return nil
+}
+
+// A block pays its rewards either to RewardAddr or, if it has any, to its
+// RewardShares, but not both.
+func (b *Block) hasValidRewardShares(network Network) bool {
+	if len(b.RewardShares) == 0 {
+		return true
+	}
+	return len(b.RewardAddr) == 0 && ValidateRewardShares(b.RewardShares, network) == nil
+}
+
+// How TotalRewards() is paid out. Each share gets its proportion of the
+// rewards rounded down, and whatever is left over from rounding goes to the
+// first share, so the payouts always add up to TotalRewards().
+func (b *Block) RewardPayouts() []TxOuput {
+	total := b.TotalRewards()
+	if len(b.RewardShares) == 0 {
+		if len(b.RewardAddr) == 0 {
+			return nil
+		}
+		return []TxOuput{{Amount: total, Address: b.RewardAddr}}
+	}
+
+	totalWeight := uint(0)
+	for _, share := range b.RewardShares {
+		totalWeight += share.Weight
+	}
+	payouts := make([]TxOuput, 0, len(b.RewardShares))
+	paid := Amount(0)
+	for _, share := range b.RewardShares {
+		// share.Weight <= totalWeight, so the quotient always fits
+		hi, lo := bits.Mul64(uint64(total), uint64(share.Weight))
+		amount, _ := bits.Div64(hi, lo, uint64(totalWeight))
+		payouts = append(payouts, TxOuput{Amount: Amount(amount), Address: share.Address})
+		paid = addCapped(paid, Amount(amount))
+	}
+	// the rounded down shares never add up to more than total
+	payouts[0].Amount = addCapped(payouts[0].Amount, total-paid)
+	return payouts
+}
@@ -0,0 +1,324 @@
+package spartan_go
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const (
+	STATE_RADIX        = 16
+	STATE_LEAF_CONST   = "STATE_LEAF"
+	STATE_BRANCH_CONST = "STATE_BRANCH"
+)
+
+// The hash standing in for a missing child, and the root of an empty state.
+var emptyStateHash = sha256.Sum256([]byte("STATE_EMPTY"))
+
+type Account struct {
+	Balance Amount
+	Nonce   uint
+}
+
+// An immutable map from address to account. Updating it returns a new state
+// that shares all untouched nodes with the old one, so every block can keep
+// its own view of the balances without copying them from its parent.
+//
+// Internally it is a hexary trie keyed by the SHA-256 of the address. A leaf
+// sits at the shallowest depth at which its key prefix is unique, so the
+// shape of the trie depends only on the set of addresses it holds. A nil
+// *AccountState is a valid empty state.
+type AccountState struct {
+	root *stateNode
+	size int
+}
+
+// A node is either a branch, with children, or a leaf holding one account.
+// Nodes are never modified once built, so their hash is computed up front.
+type stateNode struct {
+	children *[STATE_RADIX]*stateNode
+	key      [sha256.Size]byte
+	addr     string
+	account  Account
+	hash     [sha256.Size]byte
+}
+
+func newStateLeaf(addr string, account Account) *stateNode {
+	leaf := &stateNode{key: stateKey(addr), addr: addr, account: account}
+	leaf.hash = hashStateLeaf(addr, account)
+	return leaf
+}
+
+func newStateBranch(children *[STATE_RADIX]*stateNode) *stateNode {
+	hashes := make([][sha256.Size]byte, STATE_RADIX)
+	for i, child := range children {
+		hashes[i] = nodeHash(child)
+	}
+	return &stateNode{children: children, hash: hashStateBranch(hashes)}
+}
+
+func nodeHash(n *stateNode) [sha256.Size]byte {
+	if n == nil {
+		return emptyStateHash
+	}
+	return n.hash
+}
+
+// A leaf commits to the address itself rather than its hashed key, so a
+// proof shows which address the account belongs to.
+func hashStateLeaf(addr string, account Account) [sha256.Size]byte {
+	e := &encoder{}
+	e.buf = append(e.buf, STATE_LEAF_CONST...)
+	e.writeString(addr)
+	e.writeUint64(uint64(account.Balance))
+	e.writeUint64(uint64(account.Nonce))
+	return sha256.Sum256(e.buf)
+}
+
+func hashStateBranch(children [][sha256.Size]byte) [sha256.Size]byte {
+	buf := make([]byte, 0, len(STATE_BRANCH_CONST)+STATE_RADIX*sha256.Size)
+	buf = append(buf, STATE_BRANCH_CONST...)
+	for _, child := range children {
+		buf = append(buf, child[:]...)
+	}
+	return sha256.Sum256(buf)
+}
+
+func NewAccountState() *AccountState {
+	return &AccountState{}
+}
+
+func stateKey(addr string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(addr))
+}
+
+// Returns the nibble of key used to pick a child at the given depth.
+func keyNibble(key [sha256.Size]byte, depth int) int {
+	b := key[depth/2]
+	if depth%2 == 0 {
+		return int(b >> 4)
+	}
+	return int(b & 0x0f)
+}
+
+func (s *AccountState) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.size
+}
+
+func (s *AccountState) Get(addr string) (Account, bool) {
+	if s == nil {
+		return Account{}, false
+	}
+	key := stateKey(addr)
+	n := s.root
+	for depth := 0; n != nil; depth++ {
+		if n.children == nil {
+			if n.addr == addr {
+				return n.account, true
+			}
+			return Account{}, false
+		}
+		n = n.children[keyNibble(key, depth)]
+	}
+	return Account{}, false
+}
+
+func (s *AccountState) BalanceOf(addr string) Amount {
+	account, _ := s.Get(addr)
+	return account.Balance
+}
+
+func (s *AccountState) NextNonce(addr string) uint {
+	account, _ := s.Get(addr)
+	return account.Nonce
+}
+
+// Returns a new state with the account for addr replaced. The receiver is
+// left unchanged.
+func (s *AccountState) Set(addr string, account Account) *AccountState {
+	next := &AccountState{}
+	if s != nil {
+		next.root = s.root
+		next.size = s.size
+	}
+	leaf := newStateLeaf(addr, account)
+	var added bool
+	next.root, added = insertStateNode(next.root, leaf, 0)
+	if added {
+		next.size++
+	}
+	return next
+}
+
+func (s *AccountState) SetBalance(addr string, balance Amount) *AccountState {
+	account, _ := s.Get(addr)
+	account.Balance = balance
+	return s.Set(addr, account)
+}
+
+func (s *AccountState) SetNextNonce(addr string, nonce uint) *AccountState {
+	account, _ := s.Get(addr)
+	account.Nonce = nonce
+	return s.Set(addr, account)
+}
+
+// Copies the path from n down to where leaf belongs and reports whether the
+// leaf's address was not already present.
+func insertStateNode(n *stateNode, leaf *stateNode, depth int) (*stateNode, bool) {
+	if n == nil {
+		return leaf, true
+	}
+	if n.children == nil {
+		if n.addr == leaf.addr {
+			return leaf, false
+		}
+		children := &[STATE_RADIX]*stateNode{}
+		children[keyNibble(n.key, depth)] = n
+		i := keyNibble(leaf.key, depth)
+		children[i], _ = insertStateNode(children[i], leaf, depth+1)
+		return newStateBranch(children), true
+	}
+
+	children := *n.children
+	i := keyNibble(leaf.key, depth)
+	var added bool
+	children[i], added = insertStateNode(children[i], leaf, depth+1)
+	return newStateBranch(&children), added
+}
+
+// Calls fn for every account, in order of the hash of the address.
+func (s *AccountState) ForEach(fn func(addr string, account Account)) {
+	if s == nil {
+		return
+	}
+	var walk func(n *stateNode)
+	walk = func(n *stateNode) {
+		if n == nil {
+			return
+		}
+		if n.children == nil {
+			fn(n.addr, n.account)
+			return
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(s.root)
+}
+
+func (s *AccountState) Balances() map[string]Amount {
+	balances := make(map[string]Amount)
+	s.ForEach(func(addr string, account Account) {
+		balances[addr] = account.Balance
+	})
+	return balances
+}
+
+func (s *AccountState) Root() string {
+	if s == nil {
+		return hex.EncodeToString(emptyStateHash[:])
+	}
+	h := nodeHash(s.root)
+	return hex.EncodeToString(h[:])
+}
+
+// Proves the account held by Address in the state committed to by
+// Header.StateRoot. Branches lists the child hashes of every branch on the
+// path from the root, top down. If the address is not in the state, the path
+// ends either at an empty child or at the leaf of another address, given by
+// OtherAddr and OtherAccount, and Account is the zero account.
+type StateProof struct {
+	Address      string
+	Account      Account
+	Exists       bool
+	Header       *BlockHeader
+	Branches     [][STATE_RADIX]string
+	OtherAddr    string
+	OtherAccount Account
+}
+
+func (s *AccountState) Prove(addr string) *StateProof {
+	proof := &StateProof{Address: addr, Branches: make([][STATE_RADIX]string, 0)}
+	if s == nil {
+		return proof
+	}
+	key := stateKey(addr)
+	n := s.root
+	for depth := 0; n != nil && n.children != nil; depth++ {
+		var hashes [STATE_RADIX]string
+		for i, child := range n.children {
+			h := nodeHash(child)
+			hashes[i] = hex.EncodeToString(h[:])
+		}
+		proof.Branches = append(proof.Branches, hashes)
+		n = n.children[keyNibble(key, depth)]
+	}
+	if n != nil {
+		if n.addr == addr {
+			proof.Account = n.account
+			proof.Exists = true
+		} else {
+			proof.OtherAddr = n.addr
+			proof.OtherAccount = n.account
+		}
+	}
+	return proof
+}
+
+// Checks that proof is consistent with the given state root.
+func (p *StateProof) verifyRoot(stateRoot string) bool {
+	key := stateKey(p.Address)
+
+	var h [sha256.Size]byte
+	if p.Exists {
+		h = hashStateLeaf(p.Address, p.Account)
+	} else if p.Account != (Account{}) {
+		return false
+	} else if len(p.OtherAddr) != 0 {
+		if p.OtherAddr == p.Address {
+			return false
+		}
+		// the other leaf must sit on the path of the address being proven
+		otherKey := stateKey(p.OtherAddr)
+		for depth := 0; depth < len(p.Branches); depth++ {
+			if keyNibble(otherKey, depth) != keyNibble(key, depth) {
+				return false
+			}
+		}
+		h = hashStateLeaf(p.OtherAddr, p.OtherAccount)
+	} else {
+		h = emptyStateHash
+	}
+
+	for depth := len(p.Branches) - 1; depth >= 0; depth-- {
+		children := make([][sha256.Size]byte, STATE_RADIX)
+		for i, childHex := range p.Branches[depth] {
+			child, err := hex.DecodeString(childHex)
+			if err != nil || len(child) != sha256.Size {
+				return false
+			}
+			copy(children[i][:], child)
+		}
+		if !bytes.Equal(children[keyNibble(key, depth)][:], h[:]) {
+			return false
+		}
+		h = hashStateBranch(children)
+	}
+	return hex.EncodeToString(h[:]) == stateRoot
+}
+
+// Checks that proof shows the balance and nonce of its address as of the
+// block whose hash is blockHash.
+func VerifyStateProof(blockHash string, proof *StateProof) bool {
+	if proof == nil || proof.Header == nil {
+		return false
+	}
+	if proof.Header.HashVal() != blockHash {
+		return false
+	}
+	return proof.verifyRoot(proof.Header.StateRoot)
+}
@@ -0,0 +1,91 @@
+package spartan_go
+
+import (
+	"strconv"
+	"testing"
+)
+
+const BENCH_ACCOUNTS = 100000
+
+func benchAddresses() []string {
+	addrs := make([]string, BENCH_ACCOUNTS)
+	for i := range addrs {
+		addrs[i] = "account-" + strconv.Itoa(i)
+	}
+	return addrs
+}
+
+// What each new block does to the state: credit the previous block's rewards
+// and apply one payment.
+func BenchmarkAccountStateNextBlock100k(b *testing.B) {
+	addrs := benchAddresses()
+
+	b.Run("trie", func(b *testing.B) {
+		state := benchAccountState(addrs)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			state = benchTrieBlock(state, addrs, i)
+		}
+	})
+
+	// The maps every block used to copy from its parent before the state
+	// was shared.
+	b.Run("map-copy", func(b *testing.B) {
+		balances := make(map[string]Amount, len(addrs))
+		nonces := make(map[string]uint, len(addrs))
+		for _, addr := range addrs {
+			balances[addr] = 1000
+			nonces[addr] = 0
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			nextBalances := make(map[string]Amount, len(balances))
+			for k, v := range balances {
+				nextBalances[k] = v
+			}
+			nextNonces := make(map[string]uint, len(nonces))
+			for k, v := range nonces {
+				nextNonces[k] = v
+			}
+			miner, from, to := benchParties(addrs, i)
+			nextBalances[miner] += 25
+			nextBalances[from] -= 1
+			nextBalances[to] += 1
+			nextNonces[from]++
+			balances, nonces = nextBalances, nextNonces
+		}
+	})
+}
+
+func BenchmarkAccountStateGet100k(b *testing.B) {
+	addrs := benchAddresses()
+	state := benchAccountState(addrs)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		state.Get(addrs[i%len(addrs)])
+	}
+}
+
+func benchAccountState(addrs []string) *AccountState {
+	state := NewAccountState()
+	for _, addr := range addrs {
+		state = state.SetBalance(addr, 1000)
+	}
+	return state
+}
+
+func benchTrieBlock(state *AccountState, addrs []string, i int) *AccountState {
+	miner, from, to := benchParties(addrs, i)
+	state = state.SetBalance(miner, state.BalanceOf(miner)+25)
+	state = state.SetBalance(from, state.BalanceOf(from)-1)
+	state = state.SetBalance(to, state.BalanceOf(to)+1)
+	return state.SetNextNonce(from, state.NextNonce(from)+1)
+}
+
+func benchParties(addrs []string, i int) (string, string, string) {
+	n := len(addrs)
+	return addrs[i%n], addrs[(i*7+1)%n], addrs[(i*13+2)%n]
+}
@@ -2,16 +2,20 @@ package spartan_go
 
 import (
 	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 )
 
 type TxOuput struct {
-	Amount  uint
+	Amount  Amount
 	Address string
 }
 
 type Transaction struct {
-	Fee     uint
+	Fee     Amount
 	From    string
 	Nonce   uint
 	PubKey  rsa.PublicKey
@@ -19,9 +23,14 @@ type Transaction struct {
 	Outputs []TxOuput
 }
 
-const TX_CONST = "TX"
+const (
+	TX_CONST            = "TX"
+	TX_ENCODING_VERSION = byte(1)
 
-func NewTransaction(from string, nonce uint, pubKey rsa.PublicKey, sig string, fee uint, outputs []TxOuput) *Transaction {
+	FEE_RATE_WEIGHT = uint(1000)
+)
+
+func NewTransaction(from string, nonce uint, pubKey rsa.PublicKey, sig string, fee Amount, outputs []TxOuput) *Transaction {
 	newTx := &Transaction{
 		Fee:     fee,
 		From:    from,
@@ -36,15 +45,175 @@ func NewTransaction(from string, nonce uint, pubKey rsa.PublicKey, sig string, f
 	return newTx
 }
 
+// The canonical encoding of a transaction without its signature, which is
+// what the id and the signature commit to. Version 1 is laid out as:
+//
+//	version  byte (1)
+//	fee      uint64
+//	from     uint32 length, bytes
+//	nonce    uint64
+//	pubKey   uint32 length, modulus bytes (big-endian, minimal), uint64 exponent
+//	outputs  uint32 count, then for each: uint64 amount, uint32 length, address bytes
+//
+// All integers are big-endian.
+func (t *Transaction) encodeUnsigned(e *encoder) {
+	e.writeByte(TX_ENCODING_VERSION)
+	e.writeUint64(uint64(t.Fee))
+	e.writeString(t.From)
+	e.writeUint64(uint64(t.Nonce))
+	e.writePublicKey(t.PubKey)
+	e.writeCount(len(t.Outputs))
+	for _, output := range t.Outputs {
+		e.writeUint64(uint64(output.Amount))
+		e.writeString(output.Address)
+	}
+}
+
+func (t *Transaction) SigningBytes() []byte {
+	e := &encoder{}
+	t.encodeUnsigned(e)
+	return e.buf
+}
+
 func (t *Transaction) Id() string {
-	txWithoutSig := &Transaction{
-		Fee:     t.Fee,
+	return Hash(TX_CONST+string(t.SigningBytes()), "")
+}
+
+// The full encoding is the unsigned encoding followed by the signature as a
+// uint32 length and the raw signature bytes.
+func (t *Transaction) MarshalBinary() ([]byte, error) {
+	sig, err := hex.DecodeString(t.sig)
+	if err != nil {
+		return nil, errors.New("Transaction " + t.Id() + " has a malformed signature")
+	}
+	e := &encoder{}
+	t.encodeUnsigned(e)
+	e.writeBytes(sig)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf, nil
+}
+
+// The weight of a transaction is the length of its full encoding in bytes.
+// An unsigned transaction is weighed as if it were already signed with the
+// key of PubKey, so that its fee can be chosen before signing it.
+func (t *Transaction) Weight() uint {
+	weight := len(t.SigningBytes()) + 4
+	if len(t.sig) != 0 {
+		weight += len(t.sig) / 2
+	} else if t.PubKey.N != nil {
+		weight += t.PubKey.Size()
+	}
+	return uint(weight)
+}
+
+// The fee paid per FEE_RATE_WEIGHT weight units, rounded down.
+func (t *Transaction) FeeRate() Amount {
+	return mulCapped(t.Fee, Amount(FEE_RATE_WEIGHT)) / Amount(t.Weight())
+}
+
+// The fee a transaction of the given weight pays at feeRate, rounded up.
+func FeeForWeight(feeRate Amount, weight uint) Amount {
+	fee := mulCapped(feeRate, Amount(weight))
+	if fee%Amount(FEE_RATE_WEIGHT) != 0 {
+		return fee/Amount(FEE_RATE_WEIGHT) + 1
+	}
+	return fee / Amount(FEE_RATE_WEIGHT)
+}
+
+func (t *Transaction) UnmarshalBinary(data []byte) error {
+	d := &decoder{buf: data}
+	t.decode(d)
+	return d.finish()
+}
+
+func (t *Transaction) decode(d *decoder) {
+	if version := d.readByte(); d.err == nil && version != TX_ENCODING_VERSION {
+		d.err = errors.New("Unsupported transaction encoding version " + fmt.Sprint(version))
+		return
+	}
+	fee := d.readUint64()
+	from := d.readString()
+	nonce := d.readUint64()
+	pubKey := d.readPublicKey()
+	count := d.readCount()
+	outputs := make([]TxOuput, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		amount := d.readUint64()
+		address := d.readString()
+		outputs = append(outputs, TxOuput{Amount: Amount(amount), Address: address})
+	}
+	sig := d.readBytes()
+	if d.err != nil {
+		return
+	}
+
+	t.Fee = Amount(fee)
+	t.From = from
+	t.Nonce = uint(nonce)
+	t.PubKey = pubKey
+	t.Outputs = outputs
+	t.sig = hex.EncodeToString(sig)
+}
+
+type txOutputJSON struct {
+	Amount  Amount `json:"amount"`
+	Address string `json:"address"`
+}
+
+type txJSON struct {
+	From    string         `json:"from"`
+	Nonce   uint           `json:"nonce"`
+	Fee     Amount         `json:"fee"`
+	PubKeyN string         `json:"pubKeyN"`
+	PubKeyE int            `json:"pubKeyE"`
+	Outputs []txOutputJSON `json:"outputs"`
+	Sig     string         `json:"sig"`
+}
+
+// Public key modulus and signature are hex encoded.
+func (t *Transaction) MarshalJSON() ([]byte, error) {
+	o := &txJSON{
 		From:    t.From,
 		Nonce:   t.Nonce,
-		PubKey:  t.PubKey,
-		Outputs: t.Outputs,
+		Fee:     t.Fee,
+		PubKeyE: t.PubKey.E,
+		Outputs: make([]txOutputJSON, 0, len(t.Outputs)),
+		Sig:     t.sig,
+	}
+	if t.PubKey.N != nil {
+		o.PubKeyN = hex.EncodeToString(t.PubKey.N.Bytes())
+	}
+	for _, output := range t.Outputs {
+		o.Outputs = append(o.Outputs, txOutputJSON{Amount: output.Amount, Address: output.Address})
 	}
-	return Hash(TX_CONST+fmt.Sprintf("%+v", txWithoutSig), "")
+	return json.Marshal(o)
+}
+
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	o := &txJSON{}
+	if err := json.Unmarshal(data, o); err != nil {
+		return err
+	}
+	n, err := hex.DecodeString(o.PubKeyN)
+	if err != nil {
+		return errors.New("Invalid public key modulus " + o.PubKeyN)
+	}
+	if _, err := hex.DecodeString(o.Sig); err != nil {
+		return errors.New("Invalid transaction signature " + o.Sig)
+	}
+
+	t.From = o.From
+	t.Nonce = o.Nonce
+	t.Fee = o.Fee
+	t.PubKey = rsa.PublicKey{N: new(big.Int).SetBytes(n), E: o.PubKeyE}
+	t.Outputs = make([]TxOuput, 0, len(o.Outputs))
+	for _, output := range o.Outputs {
+		t.Outputs = append(t.Outputs, TxOuput{Amount: output.Amount, Address: output.Address})
+	}
+	t.sig = o.Sig
+	return nil
 }
 
 func (t *Transaction) Sign(privKey *rsa.PrivateKey) {
@@ -62,14 +231,34 @@ func (t *Transaction) ValidSignature() bool {
 		VerifySignature(t.PubKey, t.Id(), t.sig)
 }
 
+// Checks that the sender and every output address are addresses for the
+// network. The returned error is an *AddressError.
+func (t *Transaction) CheckAddresses(network Network) error {
+	if _, _, err := ParseAddress(t.From, network); err != nil {
+		return &AddressError{Address: t.From, Err: err}
+	}
+	for _, output := range t.Outputs {
+		if _, _, err := ParseAddress(output.Address, network); err != nil {
+			return &AddressError{Address: output.Address, Err: err}
+		}
+	}
+	return nil
+}
+
 func (t *Transaction) SufficientFunds(block *Block) bool {
-	return t.TotalOutput() <= block.Balances[t.From]
+	totalOutput, err := t.TotalOutput()
+	return err == nil && totalOutput <= block.BalanceOf(t.From)
 }
 
-func (t *Transaction) TotalOutput() uint {
+// The fee plus every output. Fails with ErrAmountOverflow if the total does
+// not fit in an Amount.
+func (t *Transaction) TotalOutput() (Amount, error) {
 	totalOutput := t.Fee
 	for _, output := range t.Outputs {
-		totalOutput += output.Amount
+		var err error
+		if totalOutput, err = totalOutput.Add(output.Amount); err != nil {
+			return 0, err
+		}
 	}
-	return totalOutput
+	return totalOutput, nil
 }
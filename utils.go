@@ -63,14 +63,6 @@ func VerifySignature(pubKey rsa.PublicKey, msg string, sig string) bool {
 	return true
 }
 
-func CalcAddress(pubKey rsa.PublicKey) string {
-	return Hash(pubKey, "base64")
-}
-
-func AddressMatchesKey(addr string, pubKey rsa.PublicKey) bool {
-	return addr == CalcAddress(pubKey)
-}
-
 func Jsonify(a interface{}) string {
 	output, _ := json.MarshalIndent(a, "", "  ")
 	return string(output)